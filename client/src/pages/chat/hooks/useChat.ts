@@ -1,5 +1,6 @@
-import { ChangeEvent, useEffect, useRef, useState } from "react";
+import { ChangeEvent, useEffect, useState } from "react";
 import { z } from "zod";
+import { useAuth } from "@clerk/clerk-react";
 import useParticipantId from "@/hooks/useParticipantId.ts";
 import { useApi } from "@/hooks/useApi.ts";
 import { Conversation } from "@/api/types.ts";
@@ -31,6 +32,28 @@ const NewEmojiReactSchema = z.object({
 
 const TypingSchema = z.object({
   participantId: z.string(),
+  state: z.enum(["started", "stopped"]),
+});
+
+const MarkReadSchema = z.object({
+  messageId: z.number(),
+});
+
+const MessagesReadSchema = z.object({
+  participantId: z.string(),
+  messageId: z.number(),
+  readAt: z.string(),
+});
+
+const PresenceSchema = z.object({
+  participantCount: z.number(),
+  deviceCount: z.number(),
+  participants: z.array(
+    z.object({
+      participantId: z.string(),
+      deviceCount: z.number(),
+    })
+  ),
 });
 
 const MessageEventSchema = z.discriminatedUnion("type", [
@@ -59,10 +82,26 @@ const MessageEventSchema = z.discriminatedUnion("type", [
     type: z.literal("typing"),
     payload: TypingSchema,
   }),
+  // Event for marking messages up to and including a message as read
+  z.object({
+    type: z.literal("mark_read"),
+    payload: MarkReadSchema,
+  }),
+  // Event for a participant having read messages, on any of their devices
+  z.object({
+    type: z.literal("messages_read"),
+    payload: MessagesReadSchema,
+  }),
+  // Event describing who is connected to the room and on how many devices
+  z.object({
+    type: z.literal("presence"),
+    payload: PresenceSchema,
+  }),
 ]);
 
 export type Message = z.infer<typeof MessageSchema>;
 export type MessageEvent = z.infer<typeof MessageEventSchema>;
+export type Presence = z.infer<typeof PresenceSchema>;
 
 type GroupedMessages = {
   key: string;
@@ -134,11 +173,15 @@ export default function useChat(roomIdentifier: string) {
   const [conversation, setConversation] = useState<Conversation | undefined>();
   const [messages, setMessages] = useState<Message[]>([]);
   const participantId = useParticipantId();
+  const { getToken } = useAuth();
   const api = useApi();
 
   const [otherParticipantIsTyping, setOtherParticipantIsTyping] = useState(false);
   const [lastTypingIndicationSent, setLastTypingIndicationSent] = useState<Date | null>(null);
-  const otherParticipantTypingTimeoutRef = useRef<NodeJS.Timeout | null>(null);
+
+  const [presence, setPresence] = useState<Presence | undefined>();
+  // The latest message each participant has read, keyed by participant ID.
+  const [lastReadMessageIds, setLastReadMessageIds] = useState<Record<string, number>>({});
 
   const [isConversationDetailsLoaded, setIsConversationDetailsLoaded] = useState(false);
   const [isWebSocketLoaded, setIsWebSocketLoaded] = useState(false);
@@ -155,14 +198,6 @@ export default function useChat(roomIdentifier: string) {
     }
   };
 
-  useEffect(() => {
-    return () => {
-      if (otherParticipantTypingTimeoutRef.current) {
-        clearTimeout(otherParticipantTypingTimeoutRef.current);
-      }
-    };
-  }, []);
-
   useEffect(() => {
     const getChatTitle = async (identifier: string) => {
       return await api<Conversation>(`/conversations/${identifier}`);
@@ -182,70 +217,97 @@ export default function useChat(roomIdentifier: string) {
   useEffect(() => {
     if (!participantId) return;
 
-    const webSocketUrl = `ws://localhost:42069/room?r=${roomIdentifier}&pid=${participantId}`;
-    const socket = new WebSocket(webSocketUrl);
-    socket.onopen = () => {
-      // Reset the messages to prevent loading the same ones again.
-      setMessages([]);
-      setIsWebSocketLoaded(true);
+    let socket: WebSocket | undefined;
+    let cancelled = false;
+
+    const handleSocket = (socket: WebSocket) => {
+      socket.onopen = () => {
+        // Reset the messages to prevent loading the same ones again.
+        setMessages([]);
+        setLastReadMessageIds({});
+        setIsWebSocketLoaded(true);
+      };
+
+      // New message received from the WebSocket.
+      socket.onmessage = (event) => {
+        const eventData = JSON.parse(event.data);
+        const result = MessageEventSchema.safeParse(eventData);
+        if (!result.success) {
+          console.error("Invalid MessageEvent format", result.error);
+          return;
+        }
+
+        const receivedEvent = result.data;
+        switch (receivedEvent.type) {
+          case "new_message":
+            setMessages((previousMessages) => [...previousMessages, receivedEvent.payload]);
+            break;
+          case "send_message":
+            console.warn("SendMessage event received, but no action taken.");
+            break;
+          case "new_emoji_react":
+            setMessages((previousMessages) =>
+              previousMessages.map((message) =>
+                message.id === receivedEvent.payload.messageId
+                  ? { ...message, emoji: receivedEvent.payload.emoji }
+                  : message
+              )
+            );
+            break;
+          case "typing":
+            // The server debounces typing events and sends a stopped event when the participant
+            // stops typing, sends a message, disconnects or has been silent for a few seconds.
+            setOtherParticipantIsTyping(receivedEvent.payload.state === "started");
+            break;
+          case "messages_read":
+            // Sent when any participant reads messages on any device, so this participant's other
+            // devices stay in sync as well as showing the sender that their messages were read.
+            setLastReadMessageIds((previous) => {
+              const { participantId, messageId } = receivedEvent.payload;
+              if ((previous[participantId] ?? 0) >= messageId) return previous;
+              return { ...previous, [participantId]: messageId };
+            });
+            break;
+          case "presence":
+            setPresence(receivedEvent.payload);
+            break;
+          case "mark_read":
+            console.warn("MarkRead event received, but no action taken.");
+            break;
+          default:
+            console.error("Unsupported event type", receivedEvent);
+        }
+      };
+
+      socket.onclose = (event) => {
+        console.warn("Closing WebSocket", event);
+        setIsWebSocketLoaded(false);
+      };
+
+      socket.onerror = (event) => {
+        console.error("error on WebSocket", event);
+      };
+
+      setWebSocket(socket);
     };
 
-    // New message received from the WebSocket.
-    socket.onmessage = (event) => {
-      const eventData = JSON.parse(event.data);
-      const result = MessageEventSchema.safeParse(eventData);
-      if (!result.success) {
-        console.error("Invalid MessageEvent format", result.error);
-        return;
-      }
+    const connect = async () => {
+      // Browsers cannot set headers when opening a WebSocket, so the session token is sent as a parameter.
+      // The server joins signed in users as themselves and only uses pid for anonymous users.
+      const token = await getToken();
+      if (cancelled) return;
 
-      const receivedEvent = result.data;
-      switch (receivedEvent.type) {
-        case "new_message":
-          setMessages((previousMessages) => [...previousMessages, receivedEvent.payload]);
-          break;
-        case "send_message":
-          console.warn("SendMessage event received, but no action taken.");
-          break;
-        case "new_emoji_react":
-          setMessages((previousMessages) =>
-            previousMessages.map((message) =>
-              message.id === receivedEvent.payload.messageId
-                ? { ...message, emoji: receivedEvent.payload.emoji }
-                : message
-            )
-          );
-          break;
-        case "typing":
-          if (!otherParticipantIsTyping) {
-            setOtherParticipantIsTyping(true);
-          }
-
-          if (otherParticipantTypingTimeoutRef.current) {
-            clearTimeout(otherParticipantTypingTimeoutRef.current);
-          }
-
-          otherParticipantTypingTimeoutRef.current = setTimeout(() => {
-            setOtherParticipantIsTyping(false);
-          }, 3000);
-
-          break;
-        default:
-          console.error("Unsupported event type", receivedEvent);
-      }
+      const params = new URLSearchParams({ r: roomIdentifier, pid: participantId });
+      if (token) params.set("token", token);
+      socket = new WebSocket(`ws://localhost:42069/room?${params}`);
+      handleSocket(socket);
     };
 
-    socket.onclose = (event) => {
-      console.warn("Closing WebSocket", event);
-      setIsWebSocketLoaded(false);
-    };
-
-    socket.onerror = (event) => {
-      console.error("error on WebSocket", event);
+    connect().catch((error) => console.error("failed to connect to the room", error));
+    return () => {
+      cancelled = true;
+      socket?.close();
     };
-
-    setWebSocket(socket);
-    return () => socket.close();
   }, [roomIdentifier, participantId]);
 
   const sendMessage = (text: string) => {
@@ -285,20 +347,38 @@ export default function useChat(roomIdentifier: string) {
   const sendTypingIndication = () => {
     if (!webSocket) throw new Error("WebSocket not available");
     if (!participantId) throw new Error("Participant ID is undefined");
-    if (!participantId) throw new Error("Participant ID is undefined");
-
-    console.log({ participantId });
 
     const event: MessageEvent = {
       type: "typing",
       payload: {
         participantId,
+        state: "started",
+      },
+    };
+
+    webSocket.send(JSON.stringify(event));
+  };
+
+  const markRead = (messageId: number) => {
+    if (!webSocket) throw new Error("WebSocket not available");
+    if (!participantId) throw new Error("Participant ID is undefined");
+    if ((lastReadMessageIds[participantId] ?? 0) >= messageId) return;
+
+    const event: MessageEvent = {
+      type: "mark_read",
+      payload: {
+        messageId,
       },
     };
 
     webSocket.send(JSON.stringify(event));
   };
 
+  const otherParticipantIsOnline =
+    presence?.participants.some((p) => p.participantId !== participantId && p.deviceCount > 0) ?? false;
+  const deviceCount =
+    presence?.participants.find((p) => p.participantId === participantId)?.deviceCount ?? 0;
+
   return {
     roomId: roomIdentifier,
     participantId,
@@ -311,5 +391,9 @@ export default function useChat(roomIdentifier: string) {
     messageCount: messages.length,
     otherParticipantIsTyping,
     handleTypingDetection,
+    markRead,
+    lastReadMessageIds,
+    otherParticipantIsOnline,
+    deviceCount,
   };
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -34,6 +35,11 @@ func run(logger *log.Logger) error {
 		return fmt.Errorf("failed to build application: %w", err)
 	}
 
+	if app.Config.Jobs.Enabled {
+		logger.Println("Starting scheduled jobs and outbox worker...")
+		go app.RunBackground(context.Background())
+	}
+
 	logger.Println("Setting up routes...")
 	mux := routes.BuildRoutesServerMux(app)
 
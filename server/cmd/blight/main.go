@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+	"paws/internal/application"
+	"paws/internal/repository"
+	"paws/pkg/blight"
+)
+
+const usage = `usage: blight <command> [arguments]
+
+Manages the blight database at BLIGHT_PATH.
+
+commands:
+  ls [prefix]                           list the blobs with paths starting with prefix
+  get <path> [file]                     write the blob to file, or to standard output
+  put [-content-type type] <path> <file>
+                                        store file, or standard input if file is -, at path
+  rm <path>                             delete the blob
+  gc [-dry-run] [-min-age duration]     remove blobs not referenced by any pet or pet photo
+  backup <file>                         copy the database to a new file while it is in use
+  vacuum                                return the space freed by deleted blobs to the file system
+  verify                                check the integrity of the database and the checksums of the blobs
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	config := application.NewAppConfig(os.Getenv)
+
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "ls":
+		err = runLs(config, args)
+	case "get":
+		err = runGet(config, args)
+	case "put":
+		err = runPut(config, args)
+	case "rm":
+		err = runRm(config, args)
+	case "gc":
+		err = runGC(config, args)
+	case "backup":
+		err = runBackup(config, args)
+	case "vacuum":
+		err = runVacuum(config, args)
+	case "verify":
+		err = runVerify(config, args)
+	default:
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func openBlight(config application.AppConfig) (*blight.Client, error) {
+	client, err := blight.NewWithOptions(config.Storage.BlightPath, blight.Options{Deduplicate: config.Storage.BlightDeduplicate})
+	if err != nil {
+		return nil, fmt.Errorf("could not open blight database: %w", err)
+	}
+	return client, nil
+}
+
+// parseArgs parses the flags of the command and checks the number of remaining arguments is between min and max.
+func parseArgs(flags *flag.FlagSet, args []string, min, max int) []string {
+	_ = flags.Parse(args)
+	if flags.NArg() < min || flags.NArg() > max {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	return flags.Args()
+}
+
+func runLs(config application.AppConfig, args []string) error {
+	args = parseArgs(flag.NewFlagSet("ls", flag.ExitOnError), args, 0, 1)
+	prefix := ""
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+
+	client, err := openBlight(config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	blobs, err := client.List(context.Background(), prefix)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, b := range blobs {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", b.Path, b.Size, b.ContentType, b.UpdatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func runGet(config application.AppConfig, args []string) error {
+	args = parseArgs(flag.NewFlagSet("get", flag.ExitOnError), args, 1, 2)
+
+	client, err := openBlight(config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	blob, err := client.Open(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	if len(args) == 1 {
+		_, err = io.Copy(os.Stdout, blob)
+		return err
+	}
+
+	f, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, blob); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runPut(config application.AppConfig, args []string) error {
+	flags := flag.NewFlagSet("put", flag.ExitOnError)
+	contentType := flags.String("content-type", "", "the content type of the blob, detected from its content if empty")
+	args = parseArgs(flags, args, 2, 2)
+
+	r, size := io.Reader(os.Stdin), int64(-1)
+	if args[1] != "-" {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stat, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		r, size = f, stat.Size()
+	}
+
+	client, err := openBlight(config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.PutWithOptions(context.Background(), args[0], r, size, blight.PutOptions{ContentType: *contentType})
+}
+
+func runRm(config application.AppConfig, args []string) error {
+	args = parseArgs(flag.NewFlagSet("rm", flag.ExitOnError), args, 1, 1)
+
+	client, err := openBlight(config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.Delete(args[0])
+}
+
+func runBackup(config application.AppConfig, args []string) error {
+	args = parseArgs(flag.NewFlagSet("backup", flag.ExitOnError), args, 1, 1)
+
+	client, err := openBlight(config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Backup(context.Background(), args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("backed up %s to %s\n", config.Storage.BlightPath, args[0])
+	return nil
+}
+
+func runVacuum(config application.AppConfig, args []string) error {
+	parseArgs(flag.NewFlagSet("vacuum", flag.ExitOnError), args, 0, 0)
+
+	client, err := openBlight(config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.Vacuum(context.Background())
+}
+
+func runVerify(config application.AppConfig, args []string) error {
+	parseArgs(flag.NewFlagSet("verify", flag.ExitOnError), args, 0, 0)
+
+	client, err := openBlight(config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	report, err := client.Verify(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, p := range report.Problems {
+		if p.Path == "" {
+			fmt.Println(p.Description)
+		} else {
+			fmt.Printf("%s: %s\n", p.Path, p.Description)
+		}
+	}
+	fmt.Printf("verified %d blobs, found %d problems\n", report.Checked, len(report.Problems))
+	if len(report.Problems) > 0 {
+		return errors.New("verification failed")
+	}
+	return nil
+}
+
+func runGC(config application.AppConfig, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report the blobs which would be removed without removing them")
+	minAge := flags.Duration("min-age", time.Hour, "only remove blobs which have not been updated for at least this long")
+	parseArgs(flags, args, 0, 0)
+
+	db, err := sqlx.Open("postgres", config.Database.ConnectionString)
+	if err != nil {
+		return fmt.Errorf("could not connect to database: %w", err)
+	}
+	defer db.Close()
+
+	referenced, err := application.ReferencedBlobs(repository.NewRepositories(db))
+	if err != nil {
+		return fmt.Errorf("could not list blob references: %w", err)
+	}
+
+	client, err := openBlight(config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	report, err := client.CollectGarbage(context.Background(), blight.GCOptions{
+		Referenced: referenced,
+		MinAge:     *minAge,
+		DryRun:     *dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	verb := "removed"
+	if *dryRun {
+		verb = "would remove"
+	}
+	for _, b := range report.Removed {
+		fmt.Printf("%s %s (%d bytes)\n", verb, b.Path, b.Size)
+	}
+	fmt.Printf("scanned %d blobs, %s %d blobs totalling %d bytes\n", report.Scanned, verb, len(report.Removed), report.RemovedBytes)
+	return nil
+}
@@ -0,0 +1 @@
+drop index if exists idx_messages_text_search;
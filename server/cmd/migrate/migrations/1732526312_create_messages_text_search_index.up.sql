@@ -0,0 +1 @@
+create index if not exists idx_messages_text_search on messages using gin (to_tsvector('english', text));
@@ -0,0 +1,4 @@
+drop table if exists conversation_participants;
+delete from conversations where kind = 'group';
+alter table conversations drop constraint if exists conversations_direct_secondary_participant_check;
+alter table conversations drop column if exists kind;
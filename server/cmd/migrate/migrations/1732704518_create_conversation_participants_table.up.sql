@@ -0,0 +1,25 @@
+-- Group conversations have no secondary participant; their members are held in conversation_participants.
+-- The existing unique constraint on (identifier, secondary_participant_id) limits each pet to one group conversation.
+alter table conversations
+    add column if not exists kind text not null default 'direct' check (kind in ('direct', 'group'));
+
+alter table conversations
+    add constraint conversations_direct_secondary_participant_check
+        check (kind = 'group' or secondary_participant_id <> '');
+
+create table if not exists conversation_participants (
+    conversation_id bigint not null references conversations (id) on delete cascade,
+    participant_id text not null,
+    role text not null check (role in ('owner', 'finder', 'volunteer')),
+    invited_by text,
+    joined_at timestamp with time zone not null default now(),
+    primary key (conversation_id, participant_id)
+);
+
+create index if not exists idx_conversation_participants_participant_id on conversation_participants (participant_id);
+
+insert into conversation_participants (conversation_id, participant_id, role, joined_at)
+select id, primary_participant_id, 'owner', created_at from conversations
+union all
+select id, secondary_participant_id, 'finder', created_at from conversations
+on conflict do nothing;
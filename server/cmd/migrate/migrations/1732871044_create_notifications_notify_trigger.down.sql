@@ -0,0 +1,2 @@
+drop trigger if exists tr_notifications_notify_created on notifications;
+drop function if exists fn_notifications_notify_created;
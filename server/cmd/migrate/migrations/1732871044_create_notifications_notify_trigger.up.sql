@@ -0,0 +1,12 @@
+create or replace function fn_notifications_notify_created()
+    returns trigger as $$
+begin
+    perform pg_notify('notifications', json_build_object('id', new.id, 'user_id', new.user_id)::text);
+    return new;
+end;
+$$ language plpgsql;
+
+create trigger tr_notifications_notify_created
+    after insert on notifications
+    for each row
+execute function fn_notifications_notify_created();
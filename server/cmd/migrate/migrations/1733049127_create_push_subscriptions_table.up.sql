@@ -0,0 +1,11 @@
+create table if not exists push_subscriptions (
+    id bigserial primary key,
+    user_id text not null,
+    endpoint text not null unique,
+    p256dh text not null,
+    auth text not null,
+    user_agent text,
+    created_at timestamp with time zone not null default now()
+);
+
+create index if not exists idx_push_subscriptions_user_id on push_subscriptions (user_id);
@@ -0,0 +1,15 @@
+create table if not exists notification_preferences (
+    user_id text primary key,
+    channels jsonb not null default '{}'::jsonb,
+    quiet_hours_start text check (quiet_hours_start ~ '^([01][0-9]|2[0-3]):[0-5][0-9]$'),
+    quiet_hours_end text check (quiet_hours_end ~ '^([01][0-9]|2[0-3]):[0-5][0-9]$'),
+    time_zone text not null default 'UTC',
+    digest_mode text not null default 'off' check (digest_mode in ('off', 'daily', 'weekly')),
+    created_at timestamp with time zone not null default now(),
+    updated_at timestamp with time zone not null default now()
+);
+
+create trigger notification_preferences_update_updated_at
+    before update on notification_preferences
+    for each row
+execute function fn_update_updated_at_timestamp();
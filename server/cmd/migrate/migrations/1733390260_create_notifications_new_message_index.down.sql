@@ -0,0 +1 @@
+drop index if exists idx_notifications_new_message_unseen;
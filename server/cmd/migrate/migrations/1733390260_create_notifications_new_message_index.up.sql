@@ -0,0 +1,5 @@
+-- At most one unseen new_message notification exists per user and conversation;
+-- further messages are coalesced into it until it has been seen.
+create unique index if not exists idx_notifications_new_message_unseen
+    on notifications (user_id, (detail ->> 'conversation_id'))
+    where type = 'new_message' and seen_at is null;
@@ -0,0 +1,2 @@
+create index if not exists idx_notifications_seen_at on notifications (seen_at)
+    where seen_at is not null;
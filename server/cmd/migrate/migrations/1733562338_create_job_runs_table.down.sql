@@ -0,0 +1,2 @@
+drop index if exists idx_notifications_sighting_digest_period;
+drop table if exists job_runs;
@@ -0,0 +1,14 @@
+create table if not exists job_runs (
+    job_name text not null,
+    due_at timestamp with time zone not null,
+    status text not null default 'running' check (status in ('running', 'succeeded', 'failed')),
+    error text,
+    started_at timestamp with time zone not null default now(),
+    finished_at timestamp with time zone,
+    primary key (job_name, due_at)
+);
+
+-- Only one digest notification is created per user for each digest period.
+create unique index if not exists idx_notifications_sighting_digest_period
+    on notifications (user_id, (detail ->> 'period'), (detail ->> 'period_end'))
+    where type = 'sighting_digest';
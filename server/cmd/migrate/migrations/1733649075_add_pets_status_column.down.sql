@@ -0,0 +1 @@
+alter table pets drop column if exists status;
@@ -0,0 +1,3 @@
+alter table pets
+    add column if not exists status text not null default 'home'
+        check (status in ('home', 'missing'));
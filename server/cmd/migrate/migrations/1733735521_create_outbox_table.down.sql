@@ -0,0 +1,5 @@
+drop index if exists idx_notifications_pet_status_changed_event;
+drop trigger if exists tr_outbox_notify_created on outbox;
+drop function if exists fn_outbox_notify_created;
+drop view if exists outbox_dead_letters;
+drop table if exists outbox;
@@ -0,0 +1,41 @@
+create table if not exists outbox (
+    id bigserial primary key,
+    topic text not null,
+    -- key optionally identifies the event so that it is only enqueued once.
+    key text unique,
+    payload jsonb not null default '{}'::jsonb,
+    status text not null default 'pending' check (status in ('pending', 'delivered', 'dead')),
+    attempts int not null default 0,
+    next_attempt_at timestamp with time zone not null default now(),
+    last_error text,
+    created_at timestamp with time zone not null default now(),
+    delivered_at timestamp with time zone
+);
+
+create index if not exists idx_outbox_pending on outbox (next_attempt_at)
+    where status = 'pending';
+
+-- Events which could not be delivered after every retry.
+create or replace view outbox_dead_letters as
+select id, topic, key, payload, attempts, last_error, created_at
+from outbox
+where status = 'dead';
+
+-- Wake the outbox worker as soon as an event is committed rather than waiting for it to poll.
+create or replace function fn_outbox_notify_created()
+    returns trigger as $$
+begin
+    perform pg_notify('outbox', new.id::text);
+    return new;
+end;
+$$ language plpgsql;
+
+create trigger tr_outbox_notify_created
+    after insert on outbox
+    for each row
+execute function fn_outbox_notify_created();
+
+-- Pet status change notifications are only created once for each outbox event.
+create unique index if not exists idx_notifications_pet_status_changed_event
+    on notifications (user_id, (detail ->> 'event_id'))
+    where type = 'pet_status_changed';
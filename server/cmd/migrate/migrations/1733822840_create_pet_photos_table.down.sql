@@ -0,0 +1,2 @@
+update pets set avatar_uri = null where avatar_uri in (select blob_path from pet_photos);
+drop table if exists pet_photos;
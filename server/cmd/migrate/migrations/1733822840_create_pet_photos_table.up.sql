@@ -0,0 +1,12 @@
+-- Each photo is stored as a set of renditions in the blob store under blob_path.
+-- The primary photo of a pet is the one whose blob_path is the avatar_uri of the pet.
+create table if not exists pet_photos (
+    id bigserial primary key,
+    pet_id uuid not null references pets (id) on delete cascade,
+    blob_path text not null unique,
+    caption text,
+    position integer not null default 0,
+    created_at timestamp with time zone not null default now()
+);
+
+create index if not exists idx_pet_photos_pet_id_position on pet_photos (pet_id, position);
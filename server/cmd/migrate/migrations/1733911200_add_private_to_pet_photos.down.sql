@@ -0,0 +1 @@
+alter table pet_photos drop column if exists private;
@@ -0,0 +1,2 @@
+-- Private photos are only shown to the owner of the pet, and are served from signed media URLs.
+alter table pet_photos add column if not exists private boolean not null default false;
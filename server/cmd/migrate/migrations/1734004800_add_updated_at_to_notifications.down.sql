@@ -0,0 +1,7 @@
+drop trigger if exists tr_notifications_notify_created on notifications;
+create trigger tr_notifications_notify_created
+    after insert on notifications
+    for each row
+execute function fn_notifications_notify_created();
+
+alter table notifications drop column if exists updated_at;
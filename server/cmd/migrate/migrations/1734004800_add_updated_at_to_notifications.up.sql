@@ -0,0 +1,12 @@
+-- updated_at is advanced when a notification is updated in place, such as when new messages are coalesced into
+-- an unseen new_message notification, so that it is published again and notification streams resend it.
+alter table notifications add column if not exists updated_at timestamp with time zone;
+update notifications set updated_at = coalesce(created_at, now()) where updated_at is null;
+alter table notifications alter column updated_at set default now();
+alter table notifications alter column updated_at set not null;
+
+drop trigger if exists tr_notifications_notify_created on notifications;
+create trigger tr_notifications_notify_created
+    after insert or update of updated_at on notifications
+    for each row
+execute function fn_notifications_notify_created();
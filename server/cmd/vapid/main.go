@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"paws/pkg/webpush"
+)
+
+// Generates a new VAPID key pair for Web Push notifications in .env format.
+func main() {
+	keys, err := webpush.GenerateVAPIDKeys()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("VAPID_PUBLIC_KEY=%s\n", keys.PublicKey())
+	fmt.Printf("VAPID_PRIVATE_KEY=%s\n", keys.PrivateKey())
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"paws/internal/application"
+)
+
+// The worker runs the scheduled jobs and the outbox worker without serving the API.
+// When using the worker, JOBS_ENABLED should be set to false for the API so jobs are not run in both.
+func main() {
+	logger := log.New(os.Stdout, "WORKER: ", log.LstdFlags|log.Lshortfile)
+	if err := run(logger); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func run(logger *log.Logger) error {
+	logger.Println("Starting worker...")
+	app, err := application.NewApp()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	logger.Println("Building worker...")
+	if err := app.BuildWorker(); err != nil {
+		return fmt.Errorf("failed to build worker: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Println("Running scheduled jobs and outbox worker...")
+	app.RunBackground(ctx)
+	logger.Println("Worker stopped")
+	return nil
+}
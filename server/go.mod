@@ -12,6 +12,7 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.24
 	github.com/svix/svix-webhooks v1.40.0
+	golang.org/x/image v0.23.0
 )
 
 require (
@@ -1,9 +1,15 @@
 package application
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/clerk/clerk-sdk-go/v2"
@@ -13,16 +19,32 @@ import (
 	_ "github.com/lib/pq"
 
 	"paws/internal/database/model"
+	"paws/internal/notification"
+	"paws/internal/notify"
+	"paws/internal/outbox"
 	"paws/internal/repository"
+	"paws/pkg/blight"
+	"paws/pkg/blobstore"
 	"paws/pkg/chat"
+	"paws/pkg/pubsub"
+	"paws/pkg/scheduler"
+	"paws/pkg/signedurl"
+	"paws/pkg/webpush"
 )
 
 type App struct {
-	DB           *sqlx.DB
-	ChatManager  *chat.Manager
-	Repositories *repository.Repositories
-	Logger       *slog.Logger
-	Config       AppConfig
+	DB              *sqlx.DB
+	ChatManager     *chat.Manager
+	NotificationHub *pubsub.Hub[model.Notification]
+	Notifier        *notify.Notifier
+	Dispatcher      *notify.Dispatcher
+	Scheduler       *scheduler.Scheduler
+	Outbox          *outbox.Worker
+	BlobStore       blobstore.BlobStore
+	MediaSigner     *signedurl.Signer
+	Repositories    *repository.Repositories
+	Logger          *slog.Logger
+	Config          AppConfig
 }
 
 func NewApp() (*App, error) {
@@ -50,7 +72,43 @@ func (app *App) Build() error {
 		return err
 	}
 	app.configureRepositories()
+	if err := app.configureBlobStore(); err != nil {
+		return err
+	}
+	if err := app.configureMediaSigner(); err != nil {
+		return err
+	}
+	if err := app.configureNotificationTypes(); err != nil {
+		return err
+	}
+	if err := app.configureNotifier(); err != nil {
+		return err
+	}
 	app.configureChatManager()
+	app.configureNotificationHub()
+	app.configureScheduler()
+	app.configureOutbox()
+
+	return nil
+}
+
+// BuildWorker builds only what is needed to run the scheduled jobs and the outbox worker with RunBackground,
+// leaving out the blob store, chat manager and notification hub which are only used to serve the API.
+func (app *App) BuildWorker() error {
+	app.Logger.Info("building worker")
+
+	if err := app.configureDatabase(); err != nil {
+		return err
+	}
+	app.configureRepositories()
+	if err := app.configureNotificationTypes(); err != nil {
+		return err
+	}
+	if err := app.configureNotifier(); err != nil {
+		return err
+	}
+	app.configureScheduler()
+	app.configureOutbox()
 
 	return nil
 }
@@ -76,6 +134,164 @@ func (app *App) configureRepositories() {
 	app.Repositories = repository.NewRepositories(app.DB)
 }
 
+// StaticDir is the directory served publicly, without authorization, under /static/.
+const StaticDir = "./static"
+
+// configureBlobStore creates the blob store for the configured backend. Blobs are never stored in StaticDir,
+// as private photos would then be served to anyone without a signed URL.
+func (app *App) configureBlobStore() error {
+	app.Logger.Info("configuring blob store", "backend", app.Config.Storage.Backend)
+
+	switch config := app.Config.Storage; config.Backend {
+	case StorageBackendBlight:
+		if err := checkOutsideStaticDir(config.BlightPath); err != nil {
+			return fmt.Errorf("invalid BLIGHT_PATH: %w", err)
+		}
+		client, err := blight.NewWithOptions(config.BlightPath, blight.Options{
+			Deduplicate: config.BlightDeduplicate,
+			MaxReaders:  config.BlightMaxReaders,
+		})
+		if err != nil {
+			return fmt.Errorf("could not open blight database: %w", err)
+		}
+		app.BlobStore = blobstore.NewBlightStore(client)
+	case StorageBackendFilesystem:
+		if err := checkOutsideStaticDir(config.FilesystemRoot); err != nil {
+			return fmt.Errorf("invalid STORAGE_FILESYSTEM_ROOT: %w", err)
+		}
+		store, err := blobstore.NewFilesystemStore(config.FilesystemRoot)
+		if err != nil {
+			return fmt.Errorf("could not create filesystem blob store: %w", err)
+		}
+		app.BlobStore = store
+	case StorageBackendS3:
+		store, err := blobstore.NewS3Store(blobstore.S3Config{
+			Endpoint:        config.S3.Endpoint,
+			Region:          config.S3.Region,
+			Bucket:          config.S3.Bucket,
+			AccessKeyID:     config.S3.AccessKeyID,
+			SecretAccessKey: config.S3.SecretAccessKey,
+			UsePathStyle:    config.S3.UsePathStyle,
+		})
+		if err != nil {
+			return fmt.Errorf("could not create S3 blob store: %w", err)
+		}
+		app.BlobStore = store
+	default:
+		return fmt.Errorf("unknown storage backend %q", config.Backend)
+	}
+	return nil
+}
+
+// checkOutsideStaticDir returns an error if the path is StaticDir or inside it. Both the path and the file it
+// links to are checked, as the static file server follows symbolic links.
+func checkOutsideStaticDir(path string) error {
+	statics, err := absAndResolved(StaticDir)
+	if err != nil {
+		return err
+	}
+	paths, err := absAndResolved(path)
+	if err != nil {
+		return err
+	}
+	for _, static := range statics {
+		for _, p := range paths {
+			rel, err := filepath.Rel(static, p)
+			if err != nil {
+				continue
+			}
+			if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+				return fmt.Errorf("%s is inside %s, which is served publicly", path, StaticDir)
+			}
+		}
+	}
+	return nil
+}
+
+// absAndResolved returns the absolute path, and the path with symbolic links resolved if it exists.
+func absAndResolved(path string) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{abs}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil && resolved != abs {
+		paths = append(paths, resolved)
+	}
+	return paths, nil
+}
+
+// configureMediaSigner creates the signer for private media URLs. In development a random key is generated when
+// none are configured, so URLs signed before a restart stop working.
+func (app *App) configureMediaSigner() error {
+	var keys [][]byte
+	for _, key := range app.Config.Media.SigningKeys {
+		keys = append(keys, []byte(key))
+	}
+	if len(keys) == 0 {
+		if !app.Config.Environment.IsDevelopment() {
+			return errors.New("no media signing keys configured, set MEDIA_SIGNING_KEYS")
+		}
+		app.Logger.Warn("no media signing keys configured, generating a temporary key")
+		key := make([]byte, signedurl.MinKeyLength)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	signer, err := signedurl.NewSigner(keys, app.Config.Media.URLTTL)
+	if err != nil {
+		return fmt.Errorf("invalid media signing configuration: %w", err)
+	}
+	app.MediaSigner = signer
+	return nil
+}
+
+// configureNotificationTypes applies the configured overrides to the registered notification types.
+func (app *App) configureNotificationTypes() error {
+	app.Logger.Info("configuring notification types")
+
+	for _, w := range app.Config.Notifications.DedupWindows {
+		if err := notification.SetDedupWindow(w.Type, w.PetStatus, w.Window); err != nil {
+			return fmt.Errorf("could not configure dedup window: %w", err)
+		}
+	}
+	return nil
+}
+
+func (app *App) configureNotifier() error {
+	app.Logger.Info("configuring notifier")
+
+	var sender notify.Sender = notify.NewLogSender(app.Logger)
+	if smtpConfig := app.Config.SMTP; smtpConfig.Enabled() {
+		sender = notify.NewSMTPSender(smtpConfig)
+	}
+
+	notifier, err := notify.NewNotifier(sender, app.Repositories.UserRepository, app.Config.ClientBaseURL, app.Logger)
+	if err != nil {
+		return fmt.Errorf("could not create notifier: %w", err)
+	}
+	app.Notifier = notifier
+	app.Dispatcher = notify.NewDispatcher(app.Repositories.NotificationPreferencesRepository, app.Logger).WithChannel(notify.ChannelEmail, notifier)
+
+	if vapidConfig := app.Config.VAPID; vapidConfig.Enabled() {
+		keys, err := webpush.ParseVAPIDKeys(vapidConfig.PublicKey, vapidConfig.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("could not parse VAPID keys: %w", err)
+		}
+		push := notify.NewPushChannel(
+			webpush.NewClient(keys, vapidConfig.Subject),
+			app.Repositories.PushSubscriptionRepository,
+			app.Config.ClientBaseURL,
+			app.Logger)
+		app.Dispatcher.WithChannel(notify.ChannelPush, push)
+	} else {
+		app.Logger.Warn("push notifications disabled; VAPID keys are not configured")
+	}
+	return nil
+}
+
 func (app *App) configureChatManager() {
 	app.Logger.Info("configuring chat manager")
 	conversation := app.Repositories.ConversationRepository
@@ -88,18 +304,40 @@ func (app *App) configureChatManager() {
 				if err != nil {
 					return nil, err
 				}
+				participants, err := conversation.ListParticipants(conv.ID)
+				if err != nil {
+					return nil, err
+				}
 				return ConversationWrapper{
 					Conversation: conv,
+					Participants: participants,
 				}, nil
 			},
-			HandleNewMessage: func(conversationID int64, messageEvent chat.NewMessageEvent) (int64, error) {
+			HandleGroupRoomLookup: func(identifier uuid.UUID, participantID string) (chat.RoomDetail, error) {
+				conv, err := conversation.GetGroup(identifier, participantID)
+				if err != nil {
+					if errors.Is(err, repository.ErrNotFound) {
+						return nil, chat.ErrUnauthorized
+					}
+					return nil, err
+				}
+				participants, err := conversation.ListParticipants(conv.ID)
+				if err != nil {
+					return nil, err
+				}
+				return ConversationWrapper{
+					Conversation: conv,
+					Participants: participants,
+				}, nil
+			},
+			HandleNewMessage: func(conversationID int64, messageEvent chat.NewMessageEvent, connectedParticipantIDs []string) (int64, error) {
 				m := &model.Message{
 					ConversationID: conversationID,
 					SenderID:       messageEvent.SenderID,
 					Text:           messageEvent.Text,
 				}
 
-				if err := conversation.CreateMessage(m); err != nil {
+				if err := conversation.CreateMessage(m, connectedParticipantIDs); err != nil {
 					return 0, err
 				}
 				return m.ID, nil
@@ -115,6 +353,9 @@ func (app *App) configureChatManager() {
 				}
 				return nil
 			},
+			HandleMessageRead: func(messageID int64, participantID string) error {
+				return conversation.MarkMessageRead(messageID, participantID)
+			},
 			FetchHistoricalMessages: func(conversationID int64) ([]chat.MessageDetail, error) {
 				mm, err := conversation.ListHistoricalMessages(conversationID, time.Now(), 10)
 				if err != nil {
@@ -131,3 +372,37 @@ func (app *App) configureChatManager() {
 		},
 	})
 }
+
+// configureNotificationHub creates the hub that new notifications are published to, keyed by user ID.
+// Notifications are received via Postgres LISTEN/NOTIFY so that notifications created by any instance
+// are published to the subscribers connected to this instance.
+func (app *App) configureNotificationHub() {
+	app.Logger.Info("configuring notification hub")
+	app.NotificationHub = pubsub.NewHub[model.Notification]()
+
+	notifications := app.Repositories.NotificationRepository
+	listener := pubsub.NewPostgresListener(app.Config.Database.ConnectionString, "notifications", app.Logger)
+
+	go func() {
+		err := listener.Listen(context.Background(), func(payload string) {
+			var created struct {
+				ID     int64  `json:"id"`
+				UserID string `json:"user_id"`
+			}
+			if err := json.Unmarshal([]byte(payload), &created); err != nil {
+				app.Logger.Error("invalid notification payload", "payload", payload, "error", err)
+				return
+			}
+
+			n, err := notifications.Get(created.ID)
+			if err != nil {
+				app.Logger.Error("could not get created notification", "id", created.ID, "error", err)
+				return
+			}
+			app.NotificationHub.Publish(n.UserID, n)
+		})
+		if err != nil {
+			app.Logger.Error("notification listener stopped", "error", err)
+		}
+	}()
+}
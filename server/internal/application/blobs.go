@@ -0,0 +1,45 @@
+package application
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"paws/internal/repository"
+)
+
+// ReferencedBlobs returns a function reporting whether the blob at a path is referenced by a pet or pet photo,
+// from a snapshot of the pets and photos taken when it is called.
+//
+// Blobs are stored under the ID of the pet they belong to: avatars at {pet}/{rendition}, or {pet} for avatars
+// uploaded before renditions were introduced, and photos at {pet}/photos/{photo}/{rendition}.
+func ReferencedBlobs(repos *repository.Repositories) (func(path string) bool, error) {
+	petIDs, err := repos.PetRepository.ListIDs()
+	if err != nil {
+		return nil, err
+	}
+	photoPaths, err := repos.PetPhotoRepository.ListBlobPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	pets := make(map[uuid.UUID]bool, len(petIDs))
+	for _, id := range petIDs {
+		pets[id] = true
+	}
+	photos := make(map[string]bool, len(photoPaths))
+	for _, p := range photoPaths {
+		photos[p] = true
+	}
+
+	return func(path string) bool {
+		segments := strings.Split(path, "/")
+		petID, err := uuid.Parse(segments[0])
+		if err != nil || !pets[petID] {
+			return false
+		}
+		if len(segments) > 2 && segments[1] == "photos" {
+			return photos[strings.Join(segments[:3], "/")]
+		}
+		return true
+	}, nil
+}
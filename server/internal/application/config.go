@@ -3,6 +3,10 @@ package application
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
+
+	"paws/internal/notify"
 )
 
 type Environment string
@@ -31,12 +35,133 @@ type ClerkConfig struct {
 	SigningSecret string
 }
 
+// VAPIDConfig configures the keys used to identify the server to Web Push services.
+// Push notifications are disabled when no keys are configured.
+type VAPIDConfig struct {
+	PublicKey  string
+	PrivateKey string
+	// Subject is a mailto: or https: URL push services can use to contact the server operator.
+	Subject string
+}
+
+func (c VAPIDConfig) Enabled() bool {
+	return c.PublicKey != "" && c.PrivateKey != ""
+}
+
+// NotificationsConfig configures how long notifications are kept and how they are deduplicated.
+type NotificationsConfig struct {
+	// Retention is how long notifications are kept after they have been seen.
+	Retention time.Duration
+	// DedupWindows override the default dedup windows of notification types.
+	DedupWindows []DedupWindowConfig
+}
+
+// DedupWindowConfig overrides the dedup window of a notification type, optionally only for pets with a status.
+type DedupWindowConfig struct {
+	Type      string
+	PetStatus string
+	Window    time.Duration
+}
+
+// parseDedupWindows parses a comma separated list of type=window or type/pet-status=window entries,
+// for example "spotted_pet=12h,spotted_pet/missing=30m".
+func parseDedupWindows(s string) ([]DedupWindowConfig, error) {
+	var windows []DedupWindowConfig
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid dedup window %q: expected type=window", entry)
+		}
+		window, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dedup window %q: %w", entry, err)
+		}
+		notificationType, petStatus, _ := strings.Cut(key, "/")
+		windows = append(windows, DedupWindowConfig{
+			Type:      notificationType,
+			PetStatus: petStatus,
+			Window:    window,
+		})
+	}
+	return windows, nil
+}
+
+// parseList parses a comma-separated list, ignoring empty entries.
+func parseList(s string) []string {
+	var list []string
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// JobsConfig configures the background jobs: the scheduled jobs and the outbox worker.
+type JobsConfig struct {
+	// Enabled runs the background jobs within the API. It may be disabled when they are run by cmd/worker instead.
+	Enabled bool
+}
+
+const (
+	StorageBackendBlight     = "blight"
+	StorageBackendFilesystem = "filesystem"
+	StorageBackendS3         = "s3"
+)
+
+// StorageConfig configures where blobs, such as pet avatars, are stored.
+type StorageConfig struct {
+	// Backend is one of blight, filesystem or s3.
+	Backend string
+	// BlightPath is the path of the SQLite database used by the blight backend.
+	BlightPath string
+	// BlightDeduplicate stores identical blobs once in the blight database.
+	BlightDeduplicate bool
+	// BlightMaxReaders is the most blobs the blight backend reads at once, as each blob being served holds a
+	// read connection until it has been sent.
+	BlightMaxReaders int
+	// FilesystemRoot is the directory used by the filesystem backend.
+	FilesystemRoot string
+	S3             S3StorageConfig
+}
+
+// S3StorageConfig configures the bucket used by the s3 backend, which may be any S3-compatible service.
+type S3StorageConfig struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UsePathStyle    bool
+}
+
+// MediaConfig configures the time-limited signed URLs private media, such as private pet photos, is served from.
+type MediaConfig struct {
+	// SigningKeys are the HMAC keys URLs are signed with, each at least 32 bytes long. URLs are signed with the
+	// first key and accepted if signed with any of them, so keys can be rotated by adding the new key first
+	// and removing the old key once the URLs signed with it have expired.
+	// A random key is generated in development when none are configured.
+	SigningKeys []string
+	// URLTTL is how long signed URLs are valid for.
+	URLTTL time.Duration
+}
+
 type AppConfig struct {
 	Host          string
 	Environment   Environment
 	ClientBaseURL string
 	Database      DatabaseConfig
 	Clerk         ClerkConfig
+	SMTP          notify.SMTPConfig
+	VAPID         VAPIDConfig
+	Notifications NotificationsConfig
+	Jobs          JobsConfig
+	Storage       StorageConfig
+	Media         MediaConfig
 }
 
 func NewAppConfig(getFunc func(string) string) AppConfig {
@@ -48,11 +173,53 @@ func NewAppConfig(getFunc func(string) string) AppConfig {
 		return v
 	}
 
+	getOrDefault := func(k, defaultValue string) string {
+		if v := getFunc(k); v != "" {
+			return v
+		}
+		return defaultValue
+	}
+
 	forceDatabaseMigration, err := strconv.ParseBool(get("DATABASE_FORCE_MIGRATION"))
 	if err != nil {
 		panic(err)
 	}
 
+	notificationRetention, err := time.ParseDuration(getOrDefault("NOTIFICATION_RETENTION", "720h"))
+	if err != nil {
+		panic(err)
+	}
+
+	dedupWindows, err := parseDedupWindows(getOrDefault("NOTIFICATION_DEDUP_WINDOWS", ""))
+	if err != nil {
+		panic(err)
+	}
+
+	jobsEnabled, err := strconv.ParseBool(getOrDefault("JOBS_ENABLED", "true"))
+	if err != nil {
+		panic(err)
+	}
+
+	s3UsePathStyle, err := strconv.ParseBool(getOrDefault("S3_USE_PATH_STYLE", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	blightDeduplicate, err := strconv.ParseBool(getOrDefault("BLIGHT_DEDUPLICATE", "true"))
+	if err != nil {
+		panic(err)
+	}
+
+	blightMaxReaders, err := strconv.Atoi(getOrDefault("BLIGHT_MAX_READERS", "64"))
+	if err != nil {
+		panic(err)
+	}
+
+	mediaURLTTL, err := time.ParseDuration(getOrDefault("MEDIA_URL_TTL", "1h"))
+	if err != nil {
+		panic(err)
+	}
+
 	return AppConfig{
 		Host:          get("HOST"),
 		Environment:   Environment(get("ENVIRONMENT")),
@@ -66,5 +233,43 @@ func NewAppConfig(getFunc func(string) string) AppConfig {
 			ConnectionString: get("DATABASE_CONNECTION_STRING"),
 			ForceMigration:   forceDatabaseMigration,
 		},
+		SMTP: notify.SMTPConfig{
+			Host:     getOrDefault("SMTP_HOST", ""),
+			Port:     getOrDefault("SMTP_PORT", "587"),
+			Username: getOrDefault("SMTP_USERNAME", ""),
+			Password: getOrDefault("SMTP_PASSWORD", ""),
+			From:     getOrDefault("SMTP_FROM", "Find My Paws <no-reply@findmypaws.local>"),
+		},
+		VAPID: VAPIDConfig{
+			PublicKey:  getOrDefault("VAPID_PUBLIC_KEY", ""),
+			PrivateKey: getOrDefault("VAPID_PRIVATE_KEY", ""),
+			Subject:    getOrDefault("VAPID_SUBJECT", "mailto:admin@findmypaws.local"),
+		},
+		Notifications: NotificationsConfig{
+			Retention:    notificationRetention,
+			DedupWindows: dedupWindows,
+		},
+		Jobs: JobsConfig{
+			Enabled: jobsEnabled,
+		},
+		Storage: StorageConfig{
+			Backend:           getOrDefault("STORAGE_BACKEND", StorageBackendBlight),
+			BlightPath:        getOrDefault("BLIGHT_PATH", "./avatars.db"),
+			BlightDeduplicate: blightDeduplicate,
+			BlightMaxReaders:  blightMaxReaders,
+			FilesystemRoot:    getOrDefault("STORAGE_FILESYSTEM_ROOT", "./blobs"),
+			S3: S3StorageConfig{
+				Endpoint:        getOrDefault("S3_ENDPOINT", ""),
+				Region:          getOrDefault("S3_REGION", "us-east-1"),
+				Bucket:          getOrDefault("S3_BUCKET", ""),
+				AccessKeyID:     getOrDefault("S3_ACCESS_KEY_ID", ""),
+				SecretAccessKey: getOrDefault("S3_SECRET_ACCESS_KEY", ""),
+				UsePathStyle:    s3UsePathStyle,
+			},
+		},
+		Media: MediaConfig{
+			SigningKeys: parseList(getOrDefault("MEDIA_SIGNING_KEYS", "")),
+			URLTTL:      mediaURLTTL,
+		},
 	}
 }
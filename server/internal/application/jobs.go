@@ -0,0 +1,135 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"paws/internal/database/model"
+	"paws/internal/notification"
+	"paws/internal/response"
+	"paws/pkg/scheduler"
+)
+
+func (app *App) configureScheduler() {
+	app.Logger.Info("configuring scheduler")
+
+	app.Scheduler = scheduler.New(app.Repositories.JobRunRepository, app.Logger).
+		Add(scheduler.Job{
+			Name:     "notification_retention",
+			Schedule: scheduler.Every(time.Hour),
+			Run:      app.purgeSeenNotifications,
+		}).
+		Add(scheduler.Job{
+			Name:     "sighting_digest_daily",
+			Schedule: scheduler.Daily(8),
+			Run: func(_ context.Context, due time.Time) error {
+				return app.sendSightingDigests(response.DigestModeDaily, due.AddDate(0, 0, -1), due)
+			},
+		}).
+		Add(scheduler.Job{
+			Name:     "sighting_digest_weekly",
+			Schedule: scheduler.Weekly(time.Monday, 8),
+			Run: func(_ context.Context, due time.Time) error {
+				return app.sendSightingDigests(response.DigestModeWeekly, due.AddDate(0, 0, -7), due)
+			},
+		})
+}
+
+// purgeSeenNotifications deletes notifications seen longer ago than the configured retention period,
+// along with delivered outbox events older than the same period.
+func (app *App) purgeSeenNotifications(_ context.Context, due time.Time) error {
+	cutoff := due.Add(-app.Config.Notifications.Retention)
+	deleted, err := app.Repositories.NotificationRepository.DeleteSeenBefore(cutoff)
+	if err != nil {
+		return fmt.Errorf("could not purge seen notifications: %w", err)
+	}
+	if deleted > 0 {
+		app.Logger.Info("purged seen notifications", "count", deleted, "seenBefore", cutoff)
+	}
+
+	deleted, err = app.Repositories.OutboxRepository.DeleteDeliveredBefore(cutoff)
+	if err != nil {
+		return fmt.Errorf("could not purge delivered outbox events: %w", err)
+	}
+	if deleted > 0 {
+		app.Logger.Info("purged delivered outbox events", "count", deleted, "deliveredBefore", cutoff)
+	}
+	return nil
+}
+
+// sendSightingDigests creates a digest of the unseen spotted_pet notifications created in the
+// period for every user with the digest mode. A digest is only created once per user and period, so the job
+// may safely be run again if it fails part way through.
+func (app *App) sendSightingDigests(mode response.DigestMode, from, to time.Time) error {
+	preferences, err := app.Repositories.NotificationPreferencesRepository.ListByDigestMode(string(mode))
+	if err != nil {
+		return fmt.Errorf("could not list users with %s digests: %w", mode, err)
+	}
+
+	var errs []error
+	for _, p := range preferences {
+		if err := app.sendSightingDigest(p.UserID, mode, from, to); err != nil {
+			errs = append(errs, fmt.Errorf("user %s: %w", p.UserID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (app *App) sendSightingDigest(userID string, mode response.DigestMode, from, to time.Time) error {
+	notifications := app.Repositories.NotificationRepository
+	sightings, err := notifications.ListUnseenByType(userID, notification.TypeSpottedPet, from, to)
+	if err != nil {
+		return err
+	}
+	pets := summarisePetSightings(sightings)
+	if len(pets) == 0 {
+		return nil
+	}
+
+	detail := notification.SightingDigestDetail{
+		Period:      string(mode),
+		PeriodStart: from.UTC(),
+		PeriodEnd:   to.UTC(),
+		Pets:        pets,
+	}
+	n, err := notification.New(userID, uuid.Nil, detail)
+	if err != nil {
+		return err
+	}
+
+	_, err = notifications.CreateIfNotExists(&n)
+	return err
+}
+
+// summarisePetSightings counts the spotted_pet notifications for each pet, in the order each pet was first spotted.
+func summarisePetSightings(sightings []model.Notification) []notification.PetSightings {
+	var pets []notification.PetSightings
+	index := make(map[uuid.UUID]int)
+
+	for _, s := range sightings {
+		detail, err := notification.ParseDetail(s)
+		if err != nil {
+			continue
+		}
+		spotted, ok := detail.(*notification.SpottedPetDetail)
+		if !ok {
+			continue
+		}
+
+		i, ok := index[spotted.PetID]
+		if !ok {
+			i = len(pets)
+			index[spotted.PetID] = i
+			pets = append(pets, notification.PetSightings{
+				PetID:   spotted.PetID,
+				PetName: spotted.PetName,
+			})
+		}
+		pets[i].Sightings += spotted.Sightings()
+		pets[i].LastSpottedAt = s.CreatedAt
+	}
+	return pets
+}
@@ -0,0 +1,229 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"paws/internal/database/model"
+	"paws/internal/notification"
+	"paws/internal/outbox"
+	"paws/internal/repository"
+	"paws/pkg/pubsub"
+)
+
+func (app *App) configureOutbox() {
+	app.Logger.Info("configuring outbox")
+
+	app.Outbox = outbox.NewWorker(app.Repositories.OutboxRepository, app.Logger).
+		Handle(model.OutboxTopicNotificationCreated, app.handleNotificationCreated).
+		Handle(model.OutboxTopicNotificationDelivery, app.handleNotificationDelivery).
+		Handle(model.OutboxTopicMessageCreated, app.handleMessageCreated).
+		Handle(model.OutboxTopicPetStatusChanged, app.handlePetStatusChanged)
+}
+
+// RunBackground runs the scheduled jobs and the outbox worker until the context is cancelled.
+func (app *App) RunBackground(ctx context.Context) {
+	listener := pubsub.NewPostgresListener(app.Config.Database.ConnectionString, "outbox", app.Logger)
+	go func() {
+		err := listener.Listen(ctx, func(string) {
+			app.Outbox.Wake()
+		})
+		if err != nil && ctx.Err() == nil {
+			app.Logger.Error("outbox listener stopped", "error", err)
+		}
+	}()
+
+	go app.Outbox.Run(ctx)
+	app.Scheduler.Run(ctx)
+}
+
+// decodePayload unmarshals the payload of the event, failing permanently if it is malformed.
+func decodePayload[T any](e model.OutboxEvent) (T, error) {
+	var payload T
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		return payload, fmt.Errorf("%w: invalid %s payload: %v", outbox.ErrPermanent, e.Topic, err)
+	}
+	return payload, nil
+}
+
+// handleNotificationCreated raises a delivery event for each channel the notification should be delivered on,
+// deferred until it should be delivered. The events are keyed by notification and channel so that each is only
+// raised once.
+func (app *App) handleNotificationCreated(_ context.Context, e model.OutboxEvent) error {
+	payload, err := decodePayload[model.NotificationCreatedEvent](e)
+	if err != nil {
+		return err
+	}
+	n, err := app.Repositories.NotificationRepository.Get(payload.NotificationID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	for _, d := range app.Dispatcher.Deliveries(n) {
+		key := fmt.Sprintf("notification:%d:%s", n.ID, d.Channel)
+		err := app.Repositories.OutboxRepository.EnqueueAt(model.OutboxTopicNotificationDelivery, key, model.NotificationDeliveryEvent{
+			NotificationID: n.ID,
+			Channel:        d.Channel,
+		}, d.At)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (app *App) handleNotificationDelivery(ctx context.Context, e model.OutboxEvent) error {
+	payload, err := decodePayload[model.NotificationDeliveryEvent](e)
+	if err != nil {
+		return err
+	}
+	n, err := app.Repositories.NotificationRepository.Get(payload.NotificationID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// The notification was deleted before it could be delivered.
+			return nil
+		}
+		return err
+	}
+	return app.Dispatcher.Deliver(ctx, n, payload.Channel)
+}
+
+// handleMessageCreated creates a new_message notification for each registered participant of the conversation
+// who was not connected to the conversation's room when the message was sent. Messages are coalesced into any
+// unseen notification for the conversation, so the notification is only delivered when it is first created.
+func (app *App) handleMessageCreated(_ context.Context, e model.OutboxEvent) error {
+	payload, err := decodePayload[model.MessageCreatedEvent](e)
+	if err != nil {
+		return err
+	}
+
+	conversations := app.Repositories.ConversationRepository
+	conv, err := conversations.GetByID(payload.ConversationID)
+	if err != nil {
+		return fmt.Errorf("could not get conversation: %w", err)
+	}
+	message, err := conversations.GetMessage(payload.ConversationID, payload.MessageID)
+	if err != nil {
+		return fmt.Errorf("could not get message: %w", err)
+	}
+	participants, err := conversations.ListParticipants(payload.ConversationID)
+	if err != nil {
+		return fmt.Errorf("could not list participants: %w", err)
+	}
+
+	detail := notification.NewMessageDetail{
+		ConversationID: payload.ConversationID,
+		Identifier:     conv.Identifier,
+		SenderName:     "Someone",
+		PetName:        "your pet",
+		Text:           message.Text,
+		MessageCount:   1,
+		LastMessageID:  message.ID,
+	}
+	if pet, err := app.Repositories.PetRepository.Get(conv.Identifier); err == nil {
+		detail.PetName = pet.Name
+	}
+	if sender, err := app.Repositories.UserRepository.GetAnonymousUser(payload.SenderID); err == nil && sender.Name != "" {
+		detail.SenderName = sender.Name
+	} else if payload.SenderID == conv.PrimaryParticipantID {
+		detail.SenderName = "The owner"
+	}
+
+	var errs []error
+	for _, p := range participants {
+		if p.ParticipantID == payload.SenderID || slices.Contains(payload.ConnectedParticipantIDs, p.ParticipantID) {
+			continue
+		}
+		if err := app.createNotificationForRegisteredUser(p.ParticipantID, conv.Identifier, detail, func(n *model.Notification) error {
+			_, err := app.Repositories.NotificationRepository.CreateOrCoalesceNewMessage(n)
+			return err
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// handlePetStatusChanged notifies the registered members of the pet's search party, other than the owner,
+// that the pet has been reported missing or found.
+func (app *App) handlePetStatusChanged(_ context.Context, e model.OutboxEvent) error {
+	payload, err := decodePayload[model.PetStatusChangedEvent](e)
+	if err != nil {
+		return err
+	}
+
+	pet, err := app.Repositories.PetRepository.Get(payload.PetID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	conversations := app.Repositories.ConversationRepository
+	group, err := conversations.GetGroup(pet.ID, pet.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// The pet has no search party to notify.
+			return nil
+		}
+		return err
+	}
+	participants, err := conversations.ListParticipants(group.ID)
+	if err != nil {
+		return err
+	}
+
+	detail := notification.PetStatusChangedDetail{
+		EventID: e.ID,
+		PetID:   pet.ID,
+		PetName: pet.Name,
+		Status:  payload.Status,
+	}
+
+	var errs []error
+	for _, p := range participants {
+		if p.ParticipantID == pet.UserID {
+			continue
+		}
+		if err := app.createNotificationForRegisteredUser(p.ParticipantID, pet.ID, detail, func(n *model.Notification) error {
+			_, err := app.Repositories.NotificationRepository.CreateIfNotExists(n)
+			return err
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// createNotificationForRegisteredUser creates the notification with the create function if the user is registered.
+// Anonymous users cannot view notifications, so none are created for them.
+func (app *App) createNotificationForRegisteredUser(
+	userID string,
+	petID uuid.UUID,
+	detail notification.Detail,
+	create func(n *model.Notification) error,
+) error {
+	if _, err := app.Repositories.UserRepository.GetUser(userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("could not get user %s: %w", userID, err)
+	}
+
+	n, err := notification.New(userID, petID, detail)
+	if err != nil {
+		return fmt.Errorf("%w: %v", outbox.ErrPermanent, err)
+	}
+	if err := create(&n); err != nil {
+		return fmt.Errorf("could not create %s notification for %s: %w", detail.Type(), userID, err)
+	}
+	return nil
+}
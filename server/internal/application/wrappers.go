@@ -9,6 +9,7 @@ import (
 
 type ConversationWrapper struct {
 	*model.Conversation
+	Participants []model.ConversationParticipant
 }
 
 func (cw ConversationWrapper) ID() int64 {
@@ -19,12 +20,13 @@ func (cw ConversationWrapper) Identifier() uuid.UUID {
 	return cw.Conversation.Identifier
 }
 
-func (cw ConversationWrapper) PrimaryParticipantID() string {
-	return cw.Conversation.PrimaryParticipantID
-}
-
-func (cw ConversationWrapper) SecondaryParticipantID() string {
-	return cw.Conversation.SecondaryParticipantID
+func (cw ConversationWrapper) HasParticipant(participantID string) bool {
+	for _, p := range cw.Participants {
+		if p.ParticipantID == participantID {
+			return true
+		}
+	}
+	return false
 }
 
 type MessageWrapper struct {
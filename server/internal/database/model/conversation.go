@@ -5,15 +5,42 @@ import (
 	"time"
 )
 
+const (
+	ConversationKindDirect = "direct"
+	ConversationKindGroup  = "group"
+)
+
+const (
+	ParticipantRoleOwner     = "owner"
+	ParticipantRoleFinder    = "finder"
+	ParticipantRoleVolunteer = "volunteer"
+)
+
+// Conversation is either a direct conversation between a pet owner (primary participant) and a finder
+// (secondary participant), or a group conversation for a pet where the members are held as ConversationParticipant.
+// Group conversations have an empty SecondaryParticipantID.
 type Conversation struct {
 	ID                     int64      `db:"id"`
 	Identifier             uuid.UUID  `db:"identifier"`
+	Kind                   string     `db:"kind"`
 	PrimaryParticipantID   string     `db:"primary_participant_id"`
 	SecondaryParticipantID string     `db:"secondary_participant_id"`
 	LastMessageAt          *time.Time `db:"last_message_at"`
 	CreatedAt              time.Time  `db:"created_at"`
 }
 
+func (c Conversation) IsGroup() bool {
+	return c.Kind == ConversationKindGroup
+}
+
+type ConversationParticipant struct {
+	ConversationID int64     `db:"conversation_id"`
+	ParticipantID  string    `db:"participant_id"`
+	Role           string    `db:"role"`
+	InvitedBy      *string   `db:"invited_by"`
+	JoinedAt       time.Time `db:"joined_at"`
+}
+
 type Message struct {
 	ID             int64      `db:"id"`
 	ConversationID int64      `db:"conversation_id"`
@@ -23,3 +50,13 @@ type Message struct {
 	CreatedAt      time.Time  `db:"created_at"`
 	ReadAt         *time.Time `db:"read_at"`
 }
+
+type MessageSearchResult struct {
+	MessageID      int64     `db:"message_id"`
+	ConversationID int64     `db:"conversation_id"`
+	Identifier     uuid.UUID `db:"identifier"`
+	SenderID       string    `db:"sender_id"`
+	Snippet        string    `db:"snippet"`
+	Rank           float64   `db:"rank"`
+	CreatedAt      time.Time `db:"created_at"`
+}
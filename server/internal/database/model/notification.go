@@ -2,7 +2,6 @@ package model
 
 import (
 	"encoding/json"
-	"fmt"
 	"github.com/google/uuid"
 	"time"
 )
@@ -14,31 +13,6 @@ type Notification struct {
 	Type      string          `db:"type"`
 	Detail    json.RawMessage `db:"detail"`
 	CreatedAt time.Time       `db:"created_at"`
+	UpdatedAt time.Time       `db:"updated_at"`
 	SeenAt    *time.Time      `db:"seen_at"`
 }
-
-type SpottedPetNotificationDetail struct {
-	SpotterName string    `json:"spotter_name"`
-	IsAnonymous bool      `json:"is_anonymous"`
-	PetName     string    `json:"pet_name"`
-	PetID       uuid.UUID `json:"pet_id"`
-}
-
-func NewSpottedPetNotification(userID string, detail SpottedPetNotificationDetail) (Notification, error) {
-	if detail.PetID == uuid.Nil {
-		return Notification{}, fmt.Errorf("invalid spotted pet notification detail; PetId is required")
-	}
-
-	detailJSON, err := json.Marshal(detail)
-	if err != nil {
-		return Notification{}, fmt.Errorf("error marshalling notification detail: %w", err)
-	}
-
-	return Notification{
-		UserID:    userID,
-		PetID:     detail.PetID,
-		Type:      "spotted_pet",
-		Detail:    detailJSON,
-		CreatedAt: time.Now(),
-	}, nil
-}
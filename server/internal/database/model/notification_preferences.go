@@ -0,0 +1,17 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type NotificationPreferences struct {
+	UserID          string          `db:"user_id"`
+	Channels        json.RawMessage `db:"channels"`
+	QuietHoursStart *string         `db:"quiet_hours_start"`
+	QuietHoursEnd   *string         `db:"quiet_hours_end"`
+	TimeZone        string          `db:"time_zone"`
+	DigestMode      string          `db:"digest_mode"`
+	CreatedAt       time.Time       `db:"created_at"`
+	UpdatedAt       time.Time       `db:"updated_at"`
+}
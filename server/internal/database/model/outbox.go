@@ -0,0 +1,64 @@
+package model
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusDelivered = "delivered"
+	OutboxStatusDead      = "dead"
+)
+
+// The topics of the events written to the outbox.
+const (
+	OutboxTopicNotificationCreated  = "notification.created"
+	OutboxTopicNotificationDelivery = "notification.delivery"
+	OutboxTopicMessageCreated       = "message.created"
+	OutboxTopicPetStatusChanged     = "pet.status_changed"
+)
+
+// OutboxEvent is an outbound event written in the same transaction as the change which raised it
+// and processed by the outbox worker.
+type OutboxEvent struct {
+	ID            int64           `db:"id"`
+	Topic         string          `db:"topic"`
+	Key           *string         `db:"key"`
+	Payload       json.RawMessage `db:"payload"`
+	Status        string          `db:"status"`
+	Attempts      int             `db:"attempts"`
+	NextAttemptAt time.Time       `db:"next_attempt_at"`
+	LastError     *string         `db:"last_error"`
+	CreatedAt     time.Time       `db:"created_at"`
+	DeliveredAt   *time.Time      `db:"delivered_at"`
+}
+
+// NotificationCreatedEvent is raised when a notification is created.
+type NotificationCreatedEvent struct {
+	NotificationID int64 `json:"notification_id"`
+}
+
+// NotificationDeliveryEvent is raised for each channel a notification is to be delivered on.
+type NotificationDeliveryEvent struct {
+	NotificationID int64  `json:"notification_id"`
+	Channel        string `json:"channel"`
+}
+
+// MessageCreatedEvent is raised when a message is sent in a conversation.
+type MessageCreatedEvent struct {
+	MessageID      int64  `json:"message_id"`
+	ConversationID int64  `json:"conversation_id"`
+	SenderID       string `json:"sender_id"`
+	// ConnectedParticipantIDs are the participants who had a client connected to the conversation when
+	// the message was sent, and so received the message immediately.
+	ConnectedParticipantIDs []string `json:"connected_participant_ids"`
+}
+
+// PetStatusChangedEvent is raised when the status of a pet changes, such as when it is reported missing.
+type PetStatusChangedEvent struct {
+	PetID          uuid.UUID `json:"pet_id"`
+	PreviousStatus string    `json:"previous_status"`
+	Status         string    `json:"status"`
+}
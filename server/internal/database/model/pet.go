@@ -6,6 +6,11 @@ import (
 	"time"
 )
 
+const (
+	PetStatusHome    = "home"
+	PetStatusMissing = "missing"
+)
+
 type Pet struct {
 	ID        uuid.UUID       `db:"id"`
 	UserID    string          `db:"user_id"`
@@ -15,6 +20,7 @@ type Pet struct {
 	DOB       *time.Time      `db:"dob"`
 	AvatarURI *string         `db:"avatar_uri"`
 	Blurb     *string         `db:"blurb"`
+	Status    string          `db:"status"`
 	CreatedAt time.Time       `db:"created_at"`
 	UpdatedAt time.Time       `db:"updated_at"`
 }
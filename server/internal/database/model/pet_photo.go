@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PetPhoto struct {
+	ID    int64     `db:"id"`
+	PetID uuid.UUID `db:"pet_id"`
+	// BlobPath is the path prefix the renditions of the photo are stored under in the blob store.
+	BlobPath string  `db:"blob_path"`
+	Caption  *string `db:"caption"`
+	Position int     `db:"position"`
+	// Private photos are only shown to the owner of the pet.
+	Private   bool      `db:"private"`
+	CreatedAt time.Time `db:"created_at"`
+}
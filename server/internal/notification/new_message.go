@@ -0,0 +1,60 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const TypeNewMessage = "new_message"
+
+func init() {
+	// New message notifications are coalesced per conversation rather than suppressed;
+	// see NotificationRepository.CreateOrCoalesceNewMessage.
+	Register(Type{
+		Name:      TypeNewMessage,
+		NewDetail: func() Detail { return &NewMessageDetail{} },
+		Channels:  []string{ChannelEmail, ChannelPush},
+	})
+}
+
+// NewMessageDetail is the detail of a notification sent to a conversation participant who missed a message.
+type NewMessageDetail struct {
+	ConversationID int64     `json:"conversation_id"`
+	Identifier     uuid.UUID `json:"identifier"`
+	SenderName     string    `json:"sender_name"`
+	PetName        string    `json:"pet_name"`
+	Text           string    `json:"text"`
+	MessageCount   int       `json:"message_count"`
+	// LastMessageID is the ID of the most recent message counted by the notification.
+	LastMessageID int64 `json:"last_message_id"`
+}
+
+func (d NewMessageDetail) Type() string {
+	return TypeNewMessage
+}
+
+func (d NewMessageDetail) Message() string {
+	if d.MessageCount > 1 {
+		return fmt.Sprintf("You have %d new messages about %s", d.MessageCount, d.PetName)
+	}
+	return fmt.Sprintf("%s sent you a message about %s", d.SenderName, d.PetName)
+}
+
+func (d NewMessageDetail) Link() string {
+	return fmt.Sprintf("/conversations/%s", d.Identifier)
+}
+
+func (d NewMessageDetail) Validate() error {
+	if d.ConversationID == 0 {
+		return errors.New("ConversationID is required")
+	}
+	if d.LastMessageID == 0 {
+		return errors.New("LastMessageID is required")
+	}
+	if d.MessageCount < 1 {
+		return errors.New("MessageCount must be at least 1")
+	}
+	return nil
+}
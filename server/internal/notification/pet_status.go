@@ -0,0 +1,59 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"paws/internal/database/model"
+)
+
+const TypePetStatusChanged = "pet_status_changed"
+
+func init() {
+	Register(Type{
+		Name:      TypePetStatusChanged,
+		NewDetail: func() Detail { return &PetStatusChangedDetail{} },
+		Channels:  []string{ChannelEmail, ChannelPush},
+	})
+}
+
+// PetStatusChangedDetail is the detail of a notification sent to the members of a pet's search party
+// when the pet is reported missing or found.
+type PetStatusChangedDetail struct {
+	// EventID is the ID of the outbox event the notification was created for.
+	EventID int64     `json:"event_id"`
+	PetID   uuid.UUID `json:"pet_id"`
+	PetName string    `json:"pet_name"`
+	Status  string    `json:"status"`
+}
+
+func (d PetStatusChangedDetail) Type() string {
+	return TypePetStatusChanged
+}
+
+// Found returns true if the pet was found, rather than reported missing.
+func (d PetStatusChangedDetail) Found() bool {
+	return d.Status == model.PetStatusHome
+}
+
+func (d PetStatusChangedDetail) Message() string {
+	if d.Found() {
+		return fmt.Sprintf("%s has been found and is back home", d.PetName)
+	}
+	return fmt.Sprintf("%s has been reported missing", d.PetName)
+}
+
+func (d PetStatusChangedDetail) Link() string {
+	return fmt.Sprintf("/pet/%s", d.PetID)
+}
+
+func (d PetStatusChangedDetail) Validate() error {
+	if d.EventID == 0 {
+		return errors.New("EventID is required")
+	}
+	if d.PetID == uuid.Nil {
+		return errors.New("PetID is required")
+	}
+	return nil
+}
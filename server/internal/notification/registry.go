@@ -0,0 +1,207 @@
+// Package notification defines the notification types of the application.
+//
+// Each type is defined in its own file, which declares the type's Detail and registers the type along with its
+// dedup policy and delivery channels. Types delivered by email also require templates in the notify package.
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"paws/internal/database/model"
+)
+
+// The channels notifications may be delivered on in addition to being listed in the application.
+const (
+	ChannelEmail = "email"
+	ChannelPush  = "push"
+)
+
+// UnknownType is the name reported for notifications whose type has not been registered,
+// such as those created by a newer version of the application.
+const UnknownType = "unknown"
+
+// Detail is the type specific detail of a notification, stored as JSON in the detail column.
+type Detail interface {
+	// Type returns the name of the registered notification type the detail belongs to.
+	Type() string
+	// Message returns a short human-readable summary of the notification.
+	Message() string
+	// Link returns the client path the user should follow to act on the notification.
+	Link() string
+}
+
+// DedupPolicy determines when a new notification is a duplicate of one recently sent and should be suppressed.
+// Suppressed notifications are counted on the notification they duplicate rather than discarded.
+type DedupPolicy struct {
+	// Window is how long after a notification is created that matching notifications are suppressed.
+	Window time.Duration
+	// PetStatusWindows overrides Window for notifications about a pet with the given status.
+	PetStatusWindows map[string]time.Duration
+	// Fields are the detail JSON fields which must all match for a notification to be considered a duplicate.
+	Fields []string
+}
+
+// WindowFor returns the dedup window for a notification about a pet with the given status.
+func (p DedupPolicy) WindowFor(petStatus string) time.Duration {
+	if w, ok := p.PetStatusWindows[petStatus]; ok {
+		return w
+	}
+	return p.Window
+}
+
+// Type describes a notification type. Each type is registered once, typically in the init function
+// of the file defining its Detail.
+type Type struct {
+	Name string
+	// NewDetail returns a pointer to an empty Detail which the stored JSON detail is unmarshalled into.
+	NewDetail func() Detail
+	// Dedup is the policy for suppressing duplicate notifications; nil if notifications are never suppressed.
+	Dedup *DedupPolicy
+	// Channels are the channels the notification is delivered on.
+	Channels []string
+	// Digested is true if emails of the type are held for the user's digest, rather than sent immediately,
+	// when the user has enabled digest mode.
+	Digested bool
+	// IgnoresQuietHours is true if the notification is delivered even during the user's quiet hours,
+	// such as scheduled notifications which would otherwise never be delivered.
+	IgnoresQuietHours bool
+}
+
+// HasChannel returns true if notifications of the type are delivered on the channel.
+func (t Type) HasChannel(channel string) bool {
+	return slices.Contains(t.Channels, channel)
+}
+
+var (
+	registry   = make(map[string]Type)
+	registryMu sync.RWMutex
+)
+
+// Register registers the notification type, panicking if a type with the same name is already registered.
+func Register(t Type) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if t.Name == "" || t.Name == UnknownType || t.NewDetail == nil {
+		panic(fmt.Sprintf("notification: invalid type %q", t.Name))
+	}
+	if _, ok := registry[t.Name]; ok {
+		panic(fmt.Sprintf("notification: type %q registered twice", t.Name))
+	}
+	registry[t.Name] = t
+}
+
+// SetDedupWindow overrides the dedup window of the registered type for notifications about pets with the status,
+// or the default window of the type if petStatus is empty.
+func SetDedupWindow(name, petStatus string, window time.Duration) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	t, ok := registry[name]
+	if !ok {
+		return fmt.Errorf("unknown notification type %s", name)
+	}
+	if t.Dedup == nil {
+		return fmt.Errorf("notification type %s is not deduplicated", name)
+	}
+
+	dedup := *t.Dedup
+	if petStatus == "" {
+		dedup.Window = window
+	} else {
+		dedup.PetStatusWindows = maps.Clone(dedup.PetStatusWindows)
+		if dedup.PetStatusWindows == nil {
+			dedup.PetStatusWindows = make(map[string]time.Duration)
+		}
+		dedup.PetStatusWindows[petStatus] = window
+	}
+	t.Dedup = &dedup
+	registry[name] = t
+	return nil
+}
+
+// Lookup returns the registered type with the given name.
+func Lookup(name string) (Type, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	t, ok := registry[name]
+	return t, ok
+}
+
+// Types returns every registered type ordered by name.
+func Types() []Type {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]Type, 0, len(registry))
+	for _, t := range registry {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Name < types[j].Name
+	})
+	return types
+}
+
+// New creates the notification model for the user with the given detail.
+// The detail must belong to a registered type.
+func New(userID string, petID uuid.UUID, detail Detail) (model.Notification, error) {
+	if _, ok := Lookup(detail.Type()); !ok {
+		return model.Notification{}, fmt.Errorf("unregistered notification type %s", detail.Type())
+	}
+	if v, ok := detail.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return model.Notification{}, fmt.Errorf("invalid %s notification detail: %w", detail.Type(), err)
+		}
+	}
+
+	detailJSON, err := json.Marshal(detail)
+	if err != nil {
+		return model.Notification{}, fmt.Errorf("error marshalling notification detail: %w", err)
+	}
+
+	return model.Notification{
+		UserID:    userID,
+		PetID:     petID,
+		Type:      detail.Type(),
+		Detail:    detailJSON,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// ParseDetail unmarshals the detail of the notification into the Detail of its registered type.
+// Notifications of an unregistered type are given a generic detail so that they can still be displayed.
+func ParseDetail(m model.Notification) (Detail, error) {
+	t, ok := Lookup(m.Type)
+	if !ok {
+		return unknownDetail{}, nil
+	}
+
+	detail := t.NewDetail()
+	if err := json.Unmarshal(m.Detail, detail); err != nil {
+		return nil, fmt.Errorf("error unmarshalling %s notification detail: %w", m.Type, err)
+	}
+	return detail, nil
+}
+
+// unknownDetail is the detail of a notification whose type has not been registered.
+type unknownDetail struct{}
+
+func (unknownDetail) Type() string {
+	return UnknownType
+}
+
+func (unknownDetail) Message() string {
+	return "You have a new notification"
+}
+
+func (unknownDetail) Link() string {
+	return "/dashboard"
+}
@@ -0,0 +1,82 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const TypeSightingDigest = "sighting_digest"
+
+func init() {
+	// Digests are created on a schedule, so they are not held back by quiet hours where they would never be sent.
+	Register(Type{
+		Name:              TypeSightingDigest,
+		NewDetail:         func() Detail { return &SightingDigestDetail{} },
+		Channels:          []string{ChannelEmail},
+		IgnoresQuietHours: true,
+	})
+}
+
+// SightingDigestDetail summarises the unseen sightings of each of an owner's pets over a digest period.
+type SightingDigestDetail struct {
+	// Period is the digest mode the digest was compiled for, either daily or weekly.
+	Period      string         `json:"period"`
+	PeriodStart time.Time      `json:"period_start"`
+	PeriodEnd   time.Time      `json:"period_end"`
+	Pets        []PetSightings `json:"pets"`
+}
+
+// PetSightings is the number of times a single pet was spotted during a digest period.
+type PetSightings struct {
+	PetID         uuid.UUID `json:"pet_id"`
+	PetName       string    `json:"pet_name"`
+	Sightings     int       `json:"sightings"`
+	LastSpottedAt time.Time `json:"last_spotted_at"`
+}
+
+func (d SightingDigestDetail) Type() string {
+	return TypeSightingDigest
+}
+
+// TotalSightings returns the number of sightings across every pet in the digest.
+func (d SightingDigestDetail) TotalSightings() int {
+	total := 0
+	for _, p := range d.Pets {
+		total += p.Sightings
+	}
+	return total
+}
+
+func (d SightingDigestDetail) Message() string {
+	if len(d.Pets) == 1 {
+		return fmt.Sprintf("Your %s digest: %s was spotted %s", d.Period, d.Pets[0].PetName, pluralise(d.Pets[0].Sightings, "time"))
+	}
+	return fmt.Sprintf("Your %s digest: %s of %d pets", d.Period, pluralise(d.TotalSightings(), "sighting"), len(d.Pets))
+}
+
+func (d SightingDigestDetail) Link() string {
+	if len(d.Pets) == 1 {
+		return fmt.Sprintf("/pet/%s", d.Pets[0].PetID)
+	}
+	return "/dashboard"
+}
+
+func (d SightingDigestDetail) Validate() error {
+	if d.Period == "" {
+		return errors.New("Period is required")
+	}
+	if len(d.Pets) == 0 {
+		return errors.New("at least one pet is required")
+	}
+	return nil
+}
+
+func pluralise(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,73 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"paws/internal/database/model"
+)
+
+const TypeSpottedPet = "spotted_pet"
+
+func init() {
+	Register(Type{
+		Name:      TypeSpottedPet,
+		NewDetail: func() Detail { return &SpottedPetDetail{} },
+		// Repeat visits by the same spotter are deduplicated, but sightings by different spotters never are.
+		// Missing pets use a shorter window so owners hear about repeat visits sooner.
+		Dedup: &DedupPolicy{
+			Window: 24 * time.Hour,
+			PetStatusWindows: map[string]time.Duration{
+				model.PetStatusMissing: time.Hour,
+			},
+			Fields: []string{"pet_id", "spotter_id"},
+		},
+		Channels: []string{ChannelEmail, ChannelPush},
+		Digested: true,
+	})
+}
+
+// SpottedPetDetail is the detail of a notification sent to an owner when someone reports spotting their pet.
+type SpottedPetDetail struct {
+	SpotterName string    `json:"spotter_name"`
+	IsAnonymous bool      `json:"is_anonymous"`
+	PetName     string    `json:"pet_name"`
+	PetID       uuid.UUID `json:"pet_id"`
+	// SpotterID is the ID of the user or anonymous user who spotted the pet.
+	SpotterID string `json:"spotter_id"`
+	// SuppressedCount is the number of repeat visits by the spotter suppressed as duplicates of this notification.
+	SuppressedCount int `json:"suppressed_count,omitempty"`
+}
+
+// Sightings returns the number of sightings represented by the notification, including those suppressed.
+func (d SpottedPetDetail) Sightings() int {
+	return 1 + d.SuppressedCount
+}
+
+func (d SpottedPetDetail) Type() string {
+	return TypeSpottedPet
+}
+
+func (d SpottedPetDetail) Message() string {
+	spotter := d.SpotterName
+	if d.IsAnonymous {
+		spotter = "an anonymous user"
+	}
+	if d.SuppressedCount > 0 {
+		return fmt.Sprintf("%s has been spotted by %s, who has visited %s since", d.PetName, spotter, pluralise(d.SuppressedCount, "more time"))
+	}
+	return fmt.Sprintf("%s has been spotted by %s", d.PetName, spotter)
+}
+
+func (d SpottedPetDetail) Link() string {
+	return fmt.Sprintf("/pet/%s", d.PetID)
+}
+
+func (d SpottedPetDetail) Validate() error {
+	if d.PetID == uuid.Nil {
+		return errors.New("PetID is required")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"paws/internal/database/model"
+	"paws/internal/notification"
+	"paws/internal/repository"
+	"paws/internal/response"
+)
+
+const (
+	ChannelEmail = notification.ChannelEmail
+	ChannelPush  = notification.ChannelPush
+)
+
+// Channel delivers notifications to the user they were created for over a specific medium.
+type Channel interface {
+	Notify(ctx context.Context, m model.Notification) error
+}
+
+// Channels lists the names of every delivery channel users may set preferences for.
+var Channels = []string{ChannelEmail, ChannelPush}
+
+// Dispatcher delivers notifications over every configured channel the user's preferences allow.
+// Notifications are delivered by the outbox worker: Deliveries is used when a notification is created to determine
+// which channels to deliver it on and when, and Deliver delivers it on each of those channels so that each may be
+// retried independently.
+type Dispatcher struct {
+	channels    map[string]Channel
+	preferences repository.NotificationPreferencesRepository
+	logger      *slog.Logger
+}
+
+func NewDispatcher(preferences repository.NotificationPreferencesRepository, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		channels:    make(map[string]Channel),
+		preferences: preferences,
+		logger:      logger,
+	}
+}
+
+// WithChannel adds the channel to the dispatcher under the given name.
+func (d *Dispatcher) WithChannel(name string, c Channel) *Dispatcher {
+	d.channels[name] = c
+	return d
+}
+
+// Delivery is a channel a notification should be delivered on.
+type Delivery struct {
+	Channel string
+	// At is when the notification should be delivered, which is after the user's quiet hours.
+	At time.Time
+}
+
+// Deliveries returns the configured channels the notification should be delivered on, being those its type is
+// registered for and the user's notification preferences allow, and when it should be delivered on each.
+// Notifications created during the user's quiet hours are delivered when they end, unless the type ignores them.
+// Notifications of an unregistered type are not delivered on any channel.
+func (d *Dispatcher) Deliveries(m model.Notification) []Delivery {
+	t, ok := notification.Lookup(m.Type)
+	if !ok {
+		d.logger.Warn("not dispatching notification of unknown type", "type", m.Type, "notification", m.ID)
+		return nil
+	}
+	preferences := d.getPreferences(m.UserID)
+	at := deliverAt(preferences, t, time.Now())
+
+	var deliveries []Delivery
+	for name := range d.channels {
+		if !t.HasChannel(name) {
+			continue
+		}
+		if !shouldDeliver(preferences, t, name) {
+			d.logger.Debug("notification not delivered due to preferences", "channel", name, "notification", m.ID)
+			continue
+		}
+		deliveries = append(deliveries, Delivery{Channel: name, At: at})
+	}
+	slices.SortFunc(deliveries, func(a, b Delivery) int {
+		return strings.Compare(a.Channel, b.Channel)
+	})
+	return deliveries
+}
+
+// Deliver delivers the notification on the named channel.
+// Recipients without an email address are not considered a failure.
+func (d *Dispatcher) Deliver(ctx context.Context, m model.Notification, channel string) error {
+	c, ok := d.channels[channel]
+	if !ok {
+		return fmt.Errorf("unknown channel %s", channel)
+	}
+	if err := c.Notify(ctx, m); err != nil && !errors.Is(err, ErrNoEmailAddress) {
+		return err
+	}
+	return nil
+}
+
+// getPreferences returns the user's notification preferences, falling back to the defaults.
+func (d *Dispatcher) getPreferences(userID string) response.NotificationPreferences {
+	m, err := d.preferences.Get(userID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			d.logger.Error("error getting notification preferences", "user", userID, "error", err)
+		}
+		return response.DefaultNotificationPreferences()
+	}
+	return response.NewNotificationPreferencesFromModel(m)
+}
+
+// shouldDeliver determines if a notification of the type should be delivered on the channel.
+// Emails of digested types are held for the digest when digest mode is enabled.
+func shouldDeliver(p response.NotificationPreferences, t notification.Type, channel string) bool {
+	if !p.ChannelEnabled(response.NotificationType(t.Name), channel) {
+		return false
+	}
+	if channel == ChannelEmail && t.Digested && p.Digest != response.DigestModeOff {
+		return false
+	}
+	return true
+}
+
+// deliverAt returns when a notification of the type created at now should be delivered, which is deferred to the
+// end of the user's quiet hours unless the type ignores them.
+func deliverAt(p response.NotificationPreferences, t notification.Type, now time.Time) time.Time {
+	if t.IgnoresQuietHours {
+		return now
+	}
+	if end, ok := p.QuietHoursEnd(now); ok {
+		return end
+	}
+	return now
+}
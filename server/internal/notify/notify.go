@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	"strings"
+	texttemplate "text/template"
+
+	"paws/internal/database/model"
+	"paws/internal/notification"
+	"paws/internal/repository"
+	"paws/internal/response"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// ErrNoEmailAddress is returned when the recipient does not have an email address,
+// such as when they are an anonymous user.
+var ErrNoEmailAddress = errors.New("recipient has no email address")
+
+// EmailData is the data made available to the email templates.
+type EmailData struct {
+	// Message is a short human-readable summary of the notification.
+	Message string
+	// URL is the absolute URL the recipient should follow to act on the notification.
+	URL string
+	// Detail is the type specific detail of the notification.
+	Detail any
+}
+
+type emailTemplates struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// Notifier delivers notifications to users by email.
+// Each notification type delivered by email has subject, text and HTML templates in the templates directory
+// named after the type.
+type Notifier struct {
+	sender        Sender
+	userRepo      repository.UserRepository
+	clientBaseURL string
+	logger        *slog.Logger
+	templates     map[string]emailTemplates
+}
+
+func NewNotifier(sender Sender, userRepo repository.UserRepository, clientBaseURL string, logger *slog.Logger) (*Notifier, error) {
+	n := &Notifier{
+		sender:        sender,
+		userRepo:      userRepo,
+		clientBaseURL: strings.TrimSuffix(clientBaseURL, "/"),
+		logger:        logger,
+		templates:     make(map[string]emailTemplates),
+	}
+
+	for _, t := range notification.Types() {
+		if !t.HasChannel(ChannelEmail) {
+			continue
+		}
+		tmpl, err := parseEmailTemplates(t.Name)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s email templates: %w", t.Name, err)
+		}
+		n.templates[t.Name] = tmpl
+	}
+	return n, nil
+}
+
+func parseEmailTemplates(name string) (emailTemplates, error) {
+	subject, err := texttemplate.ParseFS(templateFS, fmt.Sprintf("templates/%s.subject.tmpl", name))
+	if err != nil {
+		return emailTemplates{}, err
+	}
+	text, err := texttemplate.ParseFS(templateFS, fmt.Sprintf("templates/%s.txt.tmpl", name))
+	if err != nil {
+		return emailTemplates{}, err
+	}
+	html, err := htmltemplate.ParseFS(templateFS, fmt.Sprintf("templates/%s.html.tmpl", name))
+	if err != nil {
+		return emailTemplates{}, err
+	}
+	return emailTemplates{subject: subject, text: text, html: html}, nil
+}
+
+// Notify emails the notification to the primary email address of the user it was created for.
+func (n *Notifier) Notify(ctx context.Context, m model.Notification) error {
+	detail, err := notification.ParseDetail(m)
+	if err != nil {
+		return err
+	}
+
+	return n.send(ctx, m.UserID, m.Type, EmailData{
+		Message: detail.Message(),
+		URL:     n.clientBaseURL + detail.Link(),
+		Detail:  detail,
+	})
+}
+
+func (n *Notifier) send(ctx context.Context, userID, emailType string, data EmailData) error {
+	user, err := n.userRepo.GetUser(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNoEmailAddress
+		}
+		return err
+	}
+	address := response.NewUserFromModel(user).PrimaryEmailAddress()
+	if address == "" {
+		return ErrNoEmailAddress
+	}
+
+	tmpl, ok := n.templates[emailType]
+	if !ok {
+		return fmt.Errorf("no email templates for %s", emailType)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return fmt.Errorf("error rendering subject: %w", err)
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return fmt.Errorf("error rendering text body: %w", err)
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return fmt.Errorf("error rendering HTML body: %w", err)
+	}
+
+	return n.sender.Send(ctx, Message{
+		Type:    emailType,
+		To:      address,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	})
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"paws/internal/database/model"
+	"paws/internal/repository"
+	"paws/internal/response"
+	"paws/pkg/webpush"
+)
+
+// PushPayload is the JSON payload delivered to the service worker of each subscribed browser.
+type PushPayload struct {
+	ID      string                    `json:"id"`
+	Type    response.NotificationType `json:"type"`
+	Title   string                    `json:"title"`
+	Body    string                    `json:"body"`
+	URL     string                    `json:"url"`
+	Created string                    `json:"createdAt"`
+}
+
+// PushChannel delivers notifications to every browser the user has subscribed with Web Push.
+// Subscriptions which the push service reports as expired are removed.
+type PushChannel struct {
+	client        *webpush.Client
+	subscriptions repository.PushSubscriptionRepository
+	clientBaseURL string
+	logger        *slog.Logger
+}
+
+func NewPushChannel(
+	client *webpush.Client,
+	subscriptions repository.PushSubscriptionRepository,
+	clientBaseURL string,
+	logger *slog.Logger,
+) *PushChannel {
+	return &PushChannel{
+		client:        client,
+		subscriptions: subscriptions,
+		clientBaseURL: strings.TrimSuffix(clientBaseURL, "/"),
+		logger:        logger,
+	}
+}
+
+func (c *PushChannel) Notify(ctx context.Context, m model.Notification) error {
+	subscriptions, err := c.subscriptions.List(m.UserID)
+	if err != nil {
+		return fmt.Errorf("error listing push subscriptions: %w", err)
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	notification, ok := response.NewNotificationFromModel(m)
+	if !ok {
+		return fmt.Errorf("could not parse notification %d", m.ID)
+	}
+	payload, err := json.Marshal(PushPayload{
+		ID:      notification.ID,
+		Type:    notification.Type,
+		Title:   "Find My Paws",
+		Body:    notification.Message,
+		URL:     c.clientBaseURL + notification.Link,
+		Created: notification.CreatedAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, s := range subscriptions {
+		sub := webpush.Subscription{
+			Endpoint: s.Endpoint,
+			P256dh:   s.P256dh,
+			Auth:     s.Auth,
+		}
+		err := c.client.Send(ctx, sub, payload, webpush.Options{Urgency: "high"})
+		if errors.Is(err, webpush.ErrSubscriptionGone) || errors.Is(err, webpush.ErrInvalidEndpoint) {
+			c.logger.Info("removing invalid push subscription", "subscription", s.ID, "error", err)
+			if err := c.subscriptions.DeleteByEndpoint(s.Endpoint); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
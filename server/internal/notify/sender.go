@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Message is an email message to be delivered to a single recipient.
+type Message struct {
+	// Type is the type of email, such as the notification type it was sent for.
+	Type    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers email messages.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// LogSender is a Sender that logs messages rather than delivering them.
+// It is used when no SMTP server has been configured, such as during local development.
+// Only the type and subject are logged, as the recipient and body contain personal information.
+type LogSender struct {
+	Logger *slog.Logger
+}
+
+func NewLogSender(logger *slog.Logger) *LogSender {
+	return &LogSender{Logger: logger}
+}
+
+func (s *LogSender) Send(_ context.Context, m Message) error {
+	s.Logger.Info("email not sent; no SMTP server configured", "type", m.Type, "subject", m.Subject)
+	return nil
+}
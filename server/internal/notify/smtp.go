@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// SMTPConfig configures the SMTP server used to send emails.
+// Emails are logged rather than sent when no Host is configured.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (c SMTPConfig) Enabled() bool {
+	return c.Host != ""
+}
+
+// SMTPSender is a Sender delivering messages via an SMTP server.
+// STARTTLS is used when the server supports it, and authentication is only attempted when a username is configured,
+// allowing the sender to be used with a local SMTP stub during development and testing.
+type SMTPSender struct {
+	config SMTPConfig
+	dialer net.Dialer
+}
+
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	return &SMTPSender{
+		config: config,
+		dialer: net.Dialer{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, m Message) error {
+	// From may include a display name, such as "Find My Paws <no-reply@findmypaws.local>", which is only valid
+	// in the From header; the envelope sender is the bare address.
+	from, err := mail.ParseAddress(s.config.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address %q: %w", s.config.From, err)
+	}
+
+	body, err := buildMessage(from, m)
+	if err != nil {
+		return fmt.Errorf("error building message: %w", err)
+	}
+
+	conn, err := s.dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.config.Host, s.config.Port))
+	if err != nil {
+		return fmt.Errorf("error connecting to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error creating SMTP client: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+			return fmt.Errorf("error starting TLS: %w", err)
+		}
+	}
+	if s.config.Username != "" {
+		auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("error authenticating with SMTP server: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("error setting sender: %w", err)
+	}
+	if err := client.Rcpt(m.To); err != nil {
+		return fmt.Errorf("error setting recipient: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("error starting message data: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("error writing message data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error sending message: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage builds a multipart/alternative MIME message containing both the text and HTML bodies.
+func buildMessage(from *mail.Address, m Message) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	}
+	for _, part := range parts {
+		if part.content == "" {
+			continue
+		}
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pw.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", m.To)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,190 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession is what the stub SMTP server received from the client.
+type smtpSession struct {
+	from string
+	rcpt []string
+	data string
+}
+
+// serveSMTPStub accepts a single connection on the listener and speaks just enough SMTP to receive one message,
+// sending the session on the returned channel once the client quits.
+func serveSMTPStub(t *testing.T, l net.Listener) <-chan smtpSession {
+	t.Helper()
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		tp := textproto.NewConn(conn)
+		var session smtpSession
+		_ = tp.PrintfLine("220 localhost ESMTP stub")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				_ = tp.PrintfLine("250-localhost")
+				_ = tp.PrintfLine("250 8BITMIME")
+			case "MAIL":
+				// The client may add parameters, such as BODY=8BITMIME, after the address.
+				address, _, _ := strings.Cut(strings.TrimPrefix(arg, "FROM:"), " ")
+				session.from = address
+				_ = tp.PrintfLine("250 OK")
+			case "RCPT":
+				session.rcpt = append(session.rcpt, strings.TrimPrefix(arg, "TO:"))
+				_ = tp.PrintfLine("250 OK")
+			case "DATA":
+				_ = tp.PrintfLine("354 send the message")
+				data, err := io.ReadAll(tp.DotReader())
+				if err != nil {
+					return
+				}
+				session.data = string(data)
+				_ = tp.PrintfLine("250 OK")
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				sessions <- session
+				return
+			default:
+				_ = tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return sessions
+}
+
+// sendToStub sends the message from the sender address to a stub SMTP server, returning what the server received.
+func sendToStub(t *testing.T, from string, m Message) smtpSession {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	sessions := serveSMTPStub(t, l)
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	sender := NewSMTPSender(SMTPConfig{Host: host, Port: port, From: from})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sender.Send(ctx, m); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	select {
+	case session := <-sessions:
+		return session
+	case <-ctx.Done():
+		t.Fatal("the stub server did not receive a message")
+		return smtpSession{}
+	}
+}
+
+func TestSMTPSenderSend(t *testing.T) {
+	session := sendToStub(t, "paws@example.com", Message{
+		Type:    "spotted_pet",
+		To:      "owner@example.com",
+		Subject: "Rex was spotted – near the park",
+		Text:    "Rex was spotted.",
+		HTML:    "<p>Rex was spotted.</p>",
+	})
+
+	if session.from != "<paws@example.com>" {
+		t.Errorf("MAIL FROM = %q, want %q", session.from, "<paws@example.com>")
+	}
+	if len(session.rcpt) != 1 || session.rcpt[0] != "<owner@example.com>" {
+		t.Errorf("RCPT TO = %q, want [<owner@example.com>]", session.rcpt)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(session.data))
+	if err != nil {
+		t.Fatalf("parsing message: %v", err)
+	}
+	if to := msg.Header.Get("To"); to != "owner@example.com" {
+		t.Errorf("To = %q, want %q", to, "owner@example.com")
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decoding subject: %v", err)
+	}
+	if subject != "Rex was spotted – near the park" {
+		t.Errorf("Subject = %q, want %q", subject, "Rex was spotted – near the park")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+	parts := make(map[string]string)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading part: %v", err)
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("reading part: %v", err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(content)
+	}
+	if parts["text/plain"] != "Rex was spotted." {
+		t.Errorf("text part = %q, want %q", parts["text/plain"], "Rex was spotted.")
+	}
+	if parts["text/html"] != "<p>Rex was spotted.</p>" {
+		t.Errorf("HTML part = %q, want %q", parts["text/html"], "<p>Rex was spotted.</p>")
+	}
+}
+
+// TestSMTPSenderSendDisplayName checks a sender with a display name, such as the default sender, is given as the
+// bare address in MAIL FROM and in full in the From header.
+func TestSMTPSenderSendDisplayName(t *testing.T) {
+	session := sendToStub(t, "Find My Paws <no-reply@findmypaws.local>", Message{
+		Type:    "spotted_pet",
+		To:      "owner@example.com",
+		Subject: "Rex was spotted",
+		Text:    "Rex was spotted.",
+	})
+
+	if session.from != "<no-reply@findmypaws.local>" {
+		t.Errorf("MAIL FROM = %q, want %q", session.from, "<no-reply@findmypaws.local>")
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(session.data))
+	if err != nil {
+		t.Fatalf("parsing message: %v", err)
+	}
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		t.Fatalf("parsing From %q: %v", msg.Header.Get("From"), err)
+	}
+	if from.Name != "Find My Paws" || from.Address != "no-reply@findmypaws.local" {
+		t.Errorf("From = %q, want %q", msg.Header.Get("From"), "Find My Paws <no-reply@findmypaws.local>")
+	}
+}
@@ -0,0 +1,148 @@
+// Package outbox processes the events written to the outbox table.
+//
+// Events are written in the same transaction as the change which raised them, so an event is raised if and only
+// if the change is committed. The worker delivers each event to the handler for its topic at least once, retrying
+// failures with exponential backoff until maxAttempts is reached, after which the event is dead lettered and can be
+// found in the outbox_dead_letters view. Handlers must therefore be idempotent.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"paws/internal/database/model"
+	"paws/internal/repository"
+)
+
+const (
+	pollInterval = 5 * time.Second
+	batchSize    = 50
+	// lease is how long a claimed event may be processed before it is assumed the worker has stopped
+	// and the event is claimed again.
+	lease = 5 * time.Minute
+
+	maxAttempts = 10
+	baseBackoff = 10 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// ErrPermanent may be wrapped by handlers to dead letter an event immediately rather than retrying it.
+var ErrPermanent = errors.New("permanent failure")
+
+// Handler processes an event from the outbox.
+type Handler func(ctx context.Context, e model.OutboxEvent) error
+
+// Worker claims due events from the outbox and passes each to the handler for its topic.
+type Worker struct {
+	repo     repository.OutboxRepository
+	handlers map[string]Handler
+	wake     chan struct{}
+	logger   *slog.Logger
+}
+
+func NewWorker(repo repository.OutboxRepository, logger *slog.Logger) *Worker {
+	return &Worker{
+		repo:     repo,
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+		logger:   logger.With("component", "outbox"),
+	}
+}
+
+// Handle registers the handler for events with the topic; handlers must be registered before calling Run.
+func (w *Worker) Handle(topic string, h Handler) *Worker {
+	w.handlers[topic] = h
+	return w
+}
+
+// Wake causes the worker to check for due events immediately rather than waiting for the next poll.
+func (w *Worker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes due events until the context is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.processDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// processDue processes batches of due events until there are none left.
+func (w *Worker) processDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		events, err := w.repo.Claim(batchSize, lease)
+		if err != nil {
+			w.logger.Error("could not claim outbox events", "error", err)
+			return
+		}
+		for _, e := range events {
+			w.process(ctx, e)
+		}
+		if len(events) < batchSize {
+			return
+		}
+	}
+}
+
+func (w *Worker) process(ctx context.Context, e model.OutboxEvent) {
+	logger := w.logger.With("event", e.ID, "topic", e.Topic, "attempt", e.Attempts)
+
+	err := w.handle(ctx, e)
+	if err == nil {
+		if err := w.repo.MarkDelivered(e.ID); err != nil {
+			logger.Error("could not mark outbox event delivered", "error", err)
+		}
+		return
+	}
+
+	var nextAttemptAt *time.Time
+	if e.Attempts < maxAttempts && !errors.Is(err, ErrPermanent) {
+		t := time.Now().Add(backoff(e.Attempts))
+		nextAttemptAt = &t
+		logger.Warn("outbox event failed; retrying", "error", err, "nextAttemptAt", t)
+	} else {
+		logger.Error("outbox event failed; dead lettering", "error", err)
+	}
+	if err := w.repo.MarkFailed(e.ID, err, nextAttemptAt); err != nil {
+		logger.Error("could not mark outbox event failed", "error", err)
+	}
+}
+
+func (w *Worker) handle(ctx context.Context, e model.OutboxEvent) (err error) {
+	h, ok := w.handlers[e.Topic]
+	if !ok {
+		return fmt.Errorf("%w: no handler for topic %s", ErrPermanent, e.Topic)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return h(ctx, e)
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
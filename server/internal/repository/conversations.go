@@ -12,13 +12,23 @@ import (
 type ConversationRepository interface {
 	Create(c *model.Conversation) error
 	Get(identifier uuid.UUID, participantID string) (*model.Conversation, error)
+	GetByID(id int64) (*model.Conversation, error)
 	GetOrCreate(identifier uuid.UUID, secondaryParticipantID string) (*model.Conversation, error)
 	List(participantID string) ([]model.Conversation, error)
 	ListHistoricalMessages(conversationID int64, toDate time.Time, lookbackDays int) ([]model.Message, error)
 	GetMessage(conversationID, messageID int64) (*model.Message, error)
 	UpdateMessage(m *model.Message) error
-	CreateMessage(m *model.Message) error
+	CreateMessage(m *model.Message, connectedParticipantIDs []string) error
+	// MarkMessageRead marks the messages sent to the participant up to the message as read.
+	// Read state is only stored for direct conversations.
 	MarkMessageRead(messageId int64, participantID string) error
+	SearchMessages(participantID, query string, limit int) ([]model.MessageSearchResult, error)
+	StreamMessages(conversationID int64, fn func(m model.Message) error) error
+	GetOrCreateGroup(identifier uuid.UUID, ownerID string) (*model.Conversation, error)
+	GetGroup(identifier uuid.UUID, participantID string) (*model.Conversation, error)
+	ListParticipants(conversationID int64) ([]model.ConversationParticipant, error)
+	AddParticipant(p *model.ConversationParticipant) error
+	RemoveParticipant(conversationID int64, participantID string) error
 }
 
 type postgresConversationRepository struct {
@@ -31,9 +41,10 @@ func NewConversationsRepository(db *sqlx.DB) ConversationRepository {
 
 func (r *postgresConversationRepository) List(participantID string) ([]model.Conversation, error) {
 	stmt := `
-		select * 
-		from conversations 
-		where primary_participant_id = $1 or secondary_participant_id = $1;`
+		select c.*
+		from conversations c
+		join conversation_participants cp on cp.conversation_id = c.id
+		where cp.participant_id = $1;`
 
 	var cc []model.Conversation
 	if err := r.db.Select(&cc, stmt, participantID); err != nil {
@@ -42,27 +53,41 @@ func (r *postgresConversationRepository) List(participantID string) ([]model.Con
 	return cc, nil
 }
 
+// Create creates a direct conversation between the primary and secondary participants.
 func (r *postgresConversationRepository) Create(c *model.Conversation) error {
 	stmt := `
-		insert into conversations (identifier, primary_participant_id, secondary_participant_id)
-		values ($1, $2, $3)
-		returning id, last_message_at, created_at;`
+		with conversation as (
+			insert into conversations (identifier, kind, primary_participant_id, secondary_participant_id)
+			values ($1, $2, $3, $4)
+			returning *
+		), participants as (
+			insert into conversation_participants (conversation_id, participant_id, role)
+			select id, primary_participant_id, $5 from conversation
+			union all
+			select id, secondary_participant_id, $6 from conversation
+		)
+		select id, kind, last_message_at, created_at from conversation;`
 
-	if err := r.db.Get(c, stmt, c.Identifier, c.PrimaryParticipantID, c.SecondaryParticipantID); err != nil {
+	err := r.db.Get(c, stmt,
+		c.Identifier, model.ConversationKindDirect, c.PrimaryParticipantID, c.SecondaryParticipantID,
+		model.ParticipantRoleOwner, model.ParticipantRoleFinder)
+	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Get returns the direct conversation for the identifier in which the participant is either participant.
 func (r *postgresConversationRepository) Get(identifier uuid.UUID, participantID string) (*model.Conversation, error) {
 	stmt := `
 		select * 
 		from conversations
 		where identifier = $1 
+		  and kind = $3
 		  and (primary_participant_id = $2 or secondary_participant_id = $2);`
 
 	var conversation model.Conversation
-	if err := r.db.Get(&conversation, stmt, identifier, participantID); err != nil {
+	if err := r.db.Get(&conversation, stmt, identifier, participantID, model.ConversationKindDirect); err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
 			return nil, err
 		}
@@ -74,13 +99,28 @@ func (r *postgresConversationRepository) Get(identifier uuid.UUID, participantID
 	return nil, ErrNotFound
 }
 
+func (r *postgresConversationRepository) GetByID(id int64) (*model.Conversation, error) {
+	var conversation model.Conversation
+	if err := r.db.Get(&conversation, "select * from conversations where id = $1;", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &conversation, nil
+}
+
 // GetOrCreate finds an existing or creates a new conversation.
 // The participantID is either the primary or secondary participant for an exising conversation, but can only
 // be the secondary participant when creating a conversation as conversations must be initialised by them.
 func (r *postgresConversationRepository) GetOrCreate(identifier uuid.UUID, participantID string) (*model.Conversation, error) {
-	stmt := `select * from conversations where identifier = $1 and (primary_participant_id = $2 or secondary_participant_id = $2);`
+	stmt := `
+		select * from conversations 
+		where identifier = $1 
+		  and kind = $3
+		  and (primary_participant_id = $2 or secondary_participant_id = $2);`
 	var conversation model.Conversation
-	if err := r.db.Get(&conversation, stmt, identifier, participantID); err != nil {
+	if err := r.db.Get(&conversation, stmt, identifier, participantID, model.ConversationKindDirect); err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
 			return nil, err
 		}
@@ -108,23 +148,32 @@ func (r *postgresConversationRepository) GetOrCreate(identifier uuid.UUID, parti
 	return &conversation, nil
 }
 
-func (r *postgresConversationRepository) CreateMessage(m *model.Message) error {
+// CreateMessage creates the message, raising a message.created event in the same transaction so that
+// participants who are not connected to the conversation can be notified.
+func (r *postgresConversationRepository) CreateMessage(m *model.Message, connectedParticipantIDs []string) error {
 	stmt := `
 		insert into messages (conversation_id, sender_id, text)
 		values ($1, $2, $3)
 		returning id, emoji_reaction, created_at, read_at;`
 
-	if err := r.db.Get(m, stmt, m.ConversationID, m.SenderID, m.Text); err != nil {
-		return err
-	}
-	return nil
+	return withTx(r.db, func(tx *sqlx.Tx) error {
+		if err := tx.Get(m, stmt, m.ConversationID, m.SenderID, m.Text); err != nil {
+			return err
+		}
+		return enqueue(tx, model.OutboxTopicMessageCreated, "", model.MessageCreatedEvent{
+			MessageID:               m.ID,
+			ConversationID:          m.ConversationID,
+			SenderID:                m.SenderID,
+			ConnectedParticipantIDs: connectedParticipantIDs,
+		})
+	})
 }
 
 func (r *postgresConversationRepository) GetMessage(conversationID, messageID int64) (*model.Message, error) {
 	stmt := `select * from messages where conversation_id = $1 and id = $2;`
 	var m model.Message
 	if err := r.db.Get(&m, stmt, conversationID, messageID); err != nil {
-		if !errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
 		return nil, err
@@ -156,30 +205,65 @@ func (r *postgresConversationRepository) ListHistoricalMessages(conversationID i
 	return mm, nil
 }
 
+// StreamMessages calls fn for every message in the conversation in the order they were sent.
+// Messages are read from the database one row at a time so that the full history is never held in memory.
+// Iteration stops at the first error returned by fn.
+func (r *postgresConversationRepository) StreamMessages(conversationID int64, fn func(m model.Message) error) error {
+	q := `
+		select *
+		from messages
+		where conversation_id = $1
+		order by created_at, id;`
+
+	rows, err := r.db.Queryx(q, conversationID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m model.Message
+		if err := rows.StructScan(&m); err != nil {
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func (r *postgresConversationRepository) MarkMessageRead(messageID int64, participantID string) error {
 	authorizationStmt := `
-		select primary_participant_id, secondary_participant_id
-		from conversations c
-		join messages m on c.id = m.conversation_id
+		select exists(
+			select 1
+			from conversation_participants cp
+			where cp.conversation_id = m.conversation_id
+			  and cp.participant_id = $2
+		)
+		from messages m
 		where m.id = $1;`
 
-	var p1, p2 string
-	if err := r.db.QueryRow(authorizationStmt, messageID).Scan(&p1, &p2); err != nil {
+	var isParticipant bool
+	if err := r.db.QueryRow(authorizationStmt, messageID, participantID).Scan(&isParticipant); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return ErrNotFound
 		}
 		return err
 	}
 
-	if p1 != participantID && p2 != participantID {
+	if !isParticipant {
 		return ErrNotAuthorized
 	}
 
+	// read_at is a single column, so it is only set in direct conversations where the reader is the only
+	// recipient; in group conversations one member reading a message does not mean the others have read it.
 	stmt := `
 		with target_message as (
-			select conversation_id, sender_id, created_at
-			from messages
-			where id = $1
+			select m.conversation_id, m.sender_id, m.created_at
+			from messages m
+			join conversations c on c.id = m.conversation_id
+			where m.id = $1 and c.kind = 'direct'
 		)
 		update messages
 		set read_at = now()
@@ -193,3 +277,130 @@ func (r *postgresConversationRepository) MarkMessageRead(messageID int64, partic
 	}
 	return nil
 }
+
+// SearchMessages performs a full-text search over the messages of all conversations the participant is a member of.
+// The snippet of each result has the matching terms wrapped in <mark> tags.
+func (r *postgresConversationRepository) SearchMessages(participantID, query string, limit int) ([]model.MessageSearchResult, error) {
+	stmt := `
+		with search as (
+			select websearch_to_tsquery('english', $2) as query
+		)
+		select m.id as message_id,
+		       m.conversation_id,
+		       c.identifier,
+		       m.sender_id,
+		       ts_headline('english', m.text, search.query,
+		                   'StartSel=<mark>, StopSel=</mark>, MaxWords=24, MinWords=8, MaxFragments=2') as snippet,
+		       ts_rank(to_tsvector('english', m.text), search.query) as rank,
+		       m.created_at
+		from messages m
+		join conversations c on c.id = m.conversation_id
+		join conversation_participants cp on cp.conversation_id = c.id and cp.participant_id = $1
+		cross join search
+		where to_tsvector('english', m.text) @@ search.query
+		order by rank desc, m.created_at desc
+		limit $3;`
+
+	results := make([]model.MessageSearchResult, 0)
+	if err := r.db.Select(&results, stmt, participantID, query, limit); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetOrCreateGroup finds or creates the group conversation for the identifier.
+// Only the owner of the pet may create the group conversation; they are added to it with the owner role.
+func (r *postgresConversationRepository) GetOrCreateGroup(identifier uuid.UUID, ownerID string) (*model.Conversation, error) {
+	var petOwnerID string
+	if err := r.db.Get(&petOwnerID, "select user_id from pets where id = $1;", identifier); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if petOwnerID != ownerID {
+		return nil, ErrNotAuthorized
+	}
+
+	stmt := `
+		with conversation as (
+			insert into conversations (identifier, kind, primary_participant_id, secondary_participant_id)
+			values ($1, $2, $3, '')
+			on conflict (identifier, secondary_participant_id) do update
+				set kind = excluded.kind
+			returning *
+		), participant as (
+			insert into conversation_participants (conversation_id, participant_id, role)
+			select id, primary_participant_id, $4 from conversation
+			on conflict do nothing
+		)
+		select * from conversation;`
+
+	var conversation model.Conversation
+	if err := r.db.Get(&conversation, stmt, identifier, model.ConversationKindGroup, ownerID, model.ParticipantRoleOwner); err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// GetGroup returns the group conversation for the identifier if the participant is a member of it.
+func (r *postgresConversationRepository) GetGroup(identifier uuid.UUID, participantID string) (*model.Conversation, error) {
+	stmt := `
+		select c.*
+		from conversations c
+		join conversation_participants cp on cp.conversation_id = c.id
+		where c.identifier = $1
+		  and c.kind = $2
+		  and cp.participant_id = $3;`
+
+	var conversation model.Conversation
+	if err := r.db.Get(&conversation, stmt, identifier, model.ConversationKindGroup, participantID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+func (r *postgresConversationRepository) ListParticipants(conversationID int64) ([]model.ConversationParticipant, error) {
+	stmt := `
+		select * 
+		from conversation_participants 
+		where conversation_id = $1
+		order by joined_at;`
+
+	pp := make([]model.ConversationParticipant, 0)
+	if err := r.db.Select(&pp, stmt, conversationID); err != nil {
+		return nil, err
+	}
+	return pp, nil
+}
+
+// AddParticipant adds the participant to the conversation, updating their role if they are already a member.
+func (r *postgresConversationRepository) AddParticipant(p *model.ConversationParticipant) error {
+	stmt := `
+		insert into conversation_participants (conversation_id, participant_id, role, invited_by)
+		values ($1, $2, $3, $4)
+		on conflict (conversation_id, participant_id) do update
+			set role = excluded.role
+		returning joined_at;`
+
+	return r.db.Get(p, stmt, p.ConversationID, p.ParticipantID, p.Role, p.InvitedBy)
+}
+
+func (r *postgresConversationRepository) RemoveParticipant(conversationID int64, participantID string) error {
+	stmt := "delete from conversation_participants where conversation_id = $1 and participant_id = $2;"
+	result, err := r.db.Exec(stmt, conversationID, participantID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
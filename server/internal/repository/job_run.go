@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// jobRunStaleAfter is how long a run may be in progress before it is assumed to have been abandoned,
+// such as when the instance running it was stopped, and may be claimed again.
+const jobRunStaleAfter = time.Hour
+
+// JobRunRepository records runs of scheduled jobs; it implements scheduler.RunStore.
+type JobRunRepository interface {
+	Claim(job string, due time.Time) (bool, error)
+	Complete(job string, due time.Time, runErr error) error
+}
+
+type postgresJobRunRepository struct {
+	db *sqlx.DB
+}
+
+func NewJobRunRepository(db *sqlx.DB) JobRunRepository {
+	return &postgresJobRunRepository{
+		db: db,
+	}
+}
+
+// Claim records the run as in progress. Runs which previously failed or were abandoned may be claimed again,
+// whereas runs which succeeded or are in progress may not.
+func (r *postgresJobRunRepository) Claim(job string, due time.Time) (bool, error) {
+	stmt := `
+		insert into job_runs (job_name, due_at)
+		values ($1, $2)
+		on conflict (job_name, due_at) do update
+			set status = 'running',
+			    error = null,
+			    started_at = now(),
+			    finished_at = null
+			where job_runs.status = 'failed'
+			   or (job_runs.status = 'running' and job_runs.started_at < now() - make_interval(secs => $3))
+		returning true;`
+
+	var claimed bool
+	if err := r.db.QueryRow(stmt, job, due, jobRunStaleAfter.Seconds()).Scan(&claimed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return claimed, nil
+}
+
+func (r *postgresJobRunRepository) Complete(job string, due time.Time, runErr error) error {
+	status := "succeeded"
+	var errorText *string
+	if runErr != nil {
+		status = "failed"
+		s := runErr.Error()
+		errorText = &s
+	}
+
+	stmt := `
+		update job_runs
+		set status = $3, error = $4, finished_at = now()
+		where job_name = $1 and due_at = $2;`
+	_, err := r.db.Exec(stmt, job, due, status, errorText)
+	return err
+}
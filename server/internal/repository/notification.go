@@ -1,19 +1,43 @@
 package repository
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"paws/internal/database/model"
-	"paws/internal/response"
+	"paws/internal/notification"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
 type NotificationRepository interface {
-	List(userID string) ([]model.Notification, error)
+	Get(id int64) (model.Notification, error)
+	List(userID string, opts ListNotificationsOptions) ([]model.Notification, error)
+	ListSince(userID string, afterID int64, overlap time.Duration) ([]model.Notification, error)
+	ListUnseenByType(userID, notificationType string, from, to time.Time) ([]model.Notification, error)
+	CountUnseen(userID string) (int, error)
 	Create(n *model.Notification) error
+	CreateOrCoalesceNewMessage(n *model.Notification) (bool, error)
+	CreateIfNotExists(n *model.Notification) (bool, error)
+	MarkSeen(userID string, id int64) error
 	MarkAllSeen(userID string) error
-	RecentlyNotified(n model.Notification) (bool, error)
+	Delete(userID string, id int64) error
+	DeleteSeenBefore(cutoff time.Time) (int64, error)
+	SuppressDuplicate(n model.Notification, window time.Duration) (bool, error)
+	CountAnonymousSightings(petID uuid.UUID, window time.Duration) (int, error)
+}
+
+// ListNotificationsOptions controls which page of notifications is listed.
+type ListNotificationsOptions struct {
+	// Before is the cursor; only notifications with an ID less than Before are listed. Zero lists from the newest.
+	Before int64
+	// Limit is the maximum number of notifications listed. Zero lists every notification.
+	Limit int
+	// UnseenOnly excludes notifications that have been seen.
+	UnseenOnly bool
 }
 
 type postgresNotificationRepository struct {
@@ -26,29 +50,173 @@ func NewNotificationRepository(db *sqlx.DB) NotificationRepository {
 	}
 }
 
-func (r *postgresNotificationRepository) List(userID string) ([]model.Notification, error) {
+// List lists a page of the user's notifications, newest first.
+func (r *postgresNotificationRepository) List(userID string, opts ListNotificationsOptions) ([]model.Notification, error) {
 	q := `
-		select * from notifications 
+		select * from notifications
 		where user_id = $1
-		order by created_at desc;`
+		  and ($2 = 0 or id < $2)
+		  and (not $3 or seen_at is null)
+		order by id desc
+		limit nullif($4, 0);`
 
 	var nn []model.Notification
-	if err := r.db.Select(&nn, q, userID); err != nil {
+	if err := r.db.Select(&nn, q, userID, opts.Before, opts.UnseenOnly, opts.Limit); err != nil {
 		return nil, err
 	}
 	return nn, nil
 }
 
+func (r *postgresNotificationRepository) Get(id int64) (model.Notification, error) {
+	var n model.Notification
+	if err := r.db.Get(&n, "select * from notifications where id = $1;", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return n, ErrNotFound
+		}
+		return n, err
+	}
+	return n, nil
+}
+
+// ListSince lists the user's notifications created after the notification with the given ID, oldest first.
+// Notification IDs may commit out of order and notifications may be updated in place, so notifications updated
+// no more than overlap before the notification with the given ID are listed too, even if they were already seen.
+func (r *postgresNotificationRepository) ListSince(userID string, afterID int64, overlap time.Duration) ([]model.Notification, error) {
+	q := `
+		select * from notifications
+		where user_id = $1
+		  and (id > $2 or updated_at >= (
+			select updated_at - make_interval(secs => $3) from notifications
+			where id = $2 and user_id = $1))
+		order by id;`
+
+	var nn []model.Notification
+	if err := r.db.Select(&nn, q, userID, afterID, overlap.Seconds()); err != nil {
+		return nil, err
+	}
+	return nn, nil
+}
+
+// ListUnseenByType lists the user's unseen notifications of the type created within [from, to), oldest first.
+func (r *postgresNotificationRepository) ListUnseenByType(userID, notificationType string, from, to time.Time) ([]model.Notification, error) {
+	q := `
+		select * from notifications
+		where user_id = $1
+		  and type = $2
+		  and seen_at is null
+		  and created_at >= $3
+		  and created_at < $4
+		order by id;`
+
+	var nn []model.Notification
+	if err := r.db.Select(&nn, q, userID, notificationType, from, to); err != nil {
+		return nil, err
+	}
+	return nn, nil
+}
+
+func (r *postgresNotificationRepository) CountUnseen(userID string) (int, error) {
+	var count int
+	q := "select count(*) from notifications where user_id = $1 and seen_at is null;"
+	if err := r.db.QueryRow(q, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Create creates the notification, raising a notification.created event in the same transaction so that
+// the notification is delivered to the user's channels.
 func (r *postgresNotificationRepository) Create(n *model.Notification) error {
 	stmt := `
-		insert into notifications (user_id, type, detail)
-		values ($1, $2, $3)
-		returning id, created_at;`
+		insert into notifications (user_id, pet_id, type, detail)
+		values ($1, $2, $3, $4)
+		returning id, created_at, updated_at;`
+
+	return withTx(r.db, func(tx *sqlx.Tx) error {
+		if err := tx.Get(n, stmt, n.UserID, nullPetID(n.PetID), n.Type, n.Detail); err != nil {
+			return err
+		}
+		return enqueueNotificationCreated(tx, n.ID)
+	})
+}
+
+// CreateIfNotExists creates the notification unless doing so would violate a unique index on notifications,
+// such as the index ensuring only one digest is created per user and period.
+// Returns true if the notification was created.
+func (r *postgresNotificationRepository) CreateIfNotExists(n *model.Notification) (bool, error) {
+	stmt := `
+		insert into notifications (user_id, pet_id, type, detail)
+		values ($1, $2, $3, $4)
+		on conflict do nothing
+		returning id, created_at, updated_at;`
+
+	created := false
+	err := withTx(r.db, func(tx *sqlx.Tx) error {
+		if err := tx.Get(n, stmt, n.UserID, nullPetID(n.PetID), n.Type, n.Detail); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		created = true
+		return enqueueNotificationCreated(tx, n.ID)
+	})
+	return created, err
+}
+
+// CreateOrCoalesceNewMessage creates the new_message notification unless the user already has an unseen
+// new_message notification for the same conversation, in which case that notification is updated with the
+// latest message and its message count is incremented. Messages which have already been counted, identified
+// by last_message_id, are ignored so that the same message may be coalesced more than once.
+// Returns true if a new notification was created.
+func (r *postgresNotificationRepository) CreateOrCoalesceNewMessage(n *model.Notification) (bool, error) {
+	stmt := `
+		insert into notifications (user_id, pet_id, type, detail)
+		values ($1, $2, 'new_message', $3)
+		on conflict (user_id, (detail ->> 'conversation_id')) where type = 'new_message' and seen_at is null
+		do update set detail = excluded.detail || jsonb_build_object(
+			'message_count', coalesce((notifications.detail ->> 'message_count')::int, 1) + 1),
+			updated_at = now()
+		where coalesce((notifications.detail ->> 'last_message_id')::bigint, 0)
+			< (excluded.detail ->> 'last_message_id')::bigint
+		returning id, created_at, updated_at, detail, (xmax = 0) as created;`
+
+	created := false
+	err := withTx(r.db, func(tx *sqlx.Tx) error {
+		err := tx.QueryRow(stmt, n.UserID, n.PetID, n.Detail).Scan(&n.ID, &n.CreatedAt, &n.UpdatedAt, &n.Detail, &created)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		if !created {
+			return nil
+		}
+		return enqueueNotificationCreated(tx, n.ID)
+	})
+	return created, err
+}
 
-	if err := r.db.Get(n, stmt, n.UserID, n.Type, n.Detail); err != nil {
-		return err
+// nullPetID returns nil for the zero UUID, so that notifications which are not about a pet, such as digests,
+// are stored without a pet_id.
+func nullPetID(petID uuid.UUID) any {
+	if petID == uuid.Nil {
+		return nil
 	}
-	return nil
+	return petID
+}
+
+func enqueueNotificationCreated(tx *sqlx.Tx, notificationID int64) error {
+	return enqueue(tx, model.OutboxTopicNotificationCreated, "", model.NotificationCreatedEvent{
+		NotificationID: notificationID,
+	})
+}
+
+// MarkSeen marks the user's notification as seen; notifications which have already been seen are unchanged.
+func (r *postgresNotificationRepository) MarkSeen(userID string, id int64) error {
+	stmt := "update notifications set seen_at = coalesce(seen_at, now()) where id = $1 and user_id = $2;"
+	return execAffectingOne(r.db, stmt, id, userID)
 }
 
 func (r *postgresNotificationRepository) MarkAllSeen(userID string) error {
@@ -56,32 +224,91 @@ func (r *postgresNotificationRepository) MarkAllSeen(userID string) error {
 	return err
 }
 
-func (r *postgresNotificationRepository) RecentlyNotified(n model.Notification) (bool, error) {
-	switch n.Type {
-	case "spotted_pet":
-		return r.spottedPetNotificationRecentlyNotified(n)
-	default:
-		return false, fmt.Errorf("unknown notification type %v", n.Type)
+func (r *postgresNotificationRepository) Delete(userID string, id int64) error {
+	return execAffectingOne(r.db, "delete from notifications where id = $1 and user_id = $2;", id, userID)
+}
+
+// DeleteSeenBefore deletes every notification seen before the cutoff, returning the number deleted.
+func (r *postgresNotificationRepository) DeleteSeenBefore(cutoff time.Time) (int64, error) {
+	res, err := r.db.Exec("delete from notifications where seen_at < $1;", cutoff)
+	if err != nil {
+		return 0, err
 	}
+	return res.RowsAffected()
 }
 
-func (r *postgresNotificationRepository) spottedPetNotificationRecentlyNotified(n model.Notification) (bool, error) {
-	var detail response.SpottedPetNotificationDetail
+// SuppressDuplicate determines if the notification duplicates one created for the same user within the window,
+// according to the dedup policy of its type. If so, the suppressed_count of the most recent duplicate is incremented
+// and true is returned; the notification should then not be created.
+// Notifications of types without a dedup policy are never considered duplicates.
+func (r *postgresNotificationRepository) SuppressDuplicate(n model.Notification, window time.Duration) (bool, error) {
+	t, ok := notification.Lookup(n.Type)
+	if !ok {
+		return false, fmt.Errorf("unknown notification type %v", n.Type)
+	}
+	if t.Dedup == nil {
+		return false, nil
+	}
+
+	var detail map[string]json.RawMessage
 	if err := json.Unmarshal(n.Detail, &detail); err != nil {
 		return false, err
 	}
 
 	q := `
-		select exists(
-			select 1 from notifications
-			where type = 'spotted_pet'
-			  and created_at >= now() - interval '1 day'
-			  and detail ->> 'pet_id' = $1
-		);`
-
-	var exists bool
-	if err := r.db.QueryRow(q, detail.PetID).Scan(&exists); err != nil {
-		return exists, err
+		update notifications
+		set detail = jsonb_set(detail, '{suppressed_count}',
+			to_jsonb(coalesce((detail ->> 'suppressed_count')::int, 0) + 1))
+		where id = (
+			select id from notifications
+			where user_id = $1
+			  and type = $2
+			  and created_at >= now() - make_interval(secs => $3)`
+	args := []any{n.UserID, n.Type, window.Seconds()}
+	for _, field := range t.Dedup.Fields {
+		args = append(args, field, detailText(detail[field]))
+		q += fmt.Sprintf("\n			  and detail ->> $%d = $%d", len(args)-1, len(args))
+	}
+	q += `
+			order by id desc
+			limit 1
+			for update)
+		returning id;`
+
+	var id int64
+	if err := r.db.QueryRow(q, args...).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// detailText returns the JSON value as it would be returned by the ->> operator.
+func detailText(v json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		return s
+	}
+	return string(v)
+}
+
+// CountAnonymousSightings counts the spotted pet notifications created for anonymous users spotting the pet
+// within the window. Repeat visits by the same anonymous user are suppressed as duplicates, so this is the number
+// of different anonymous users who spotted the pet. The pet is matched on the detail, which is indexed for
+// spotted pet notifications.
+func (r *postgresNotificationRepository) CountAnonymousSightings(petID uuid.UUID, window time.Duration) (int, error) {
+	q := `
+		select count(*) from notifications
+		where detail ->> 'pet_id' = $1
+		  and type = $2
+		  and (detail ->> 'is_anonymous')::boolean
+		  and created_at >= now() - make_interval(secs => $3);`
+
+	var count int
+	if err := r.db.QueryRow(q, petID.String(), notification.TypeSpottedPet, window.Seconds()).Scan(&count); err != nil {
+		return 0, err
 	}
-	return exists, nil
+	return count, nil
 }
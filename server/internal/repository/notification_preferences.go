@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"paws/internal/database/model"
+)
+
+type NotificationPreferencesRepository interface {
+	Get(userID string) (model.NotificationPreferences, error)
+	ListByDigestMode(mode string) ([]model.NotificationPreferences, error)
+	Upsert(p *model.NotificationPreferences) error
+}
+
+type postgresNotificationPreferencesRepository struct {
+	db *sqlx.DB
+}
+
+func NewNotificationPreferencesRepository(db *sqlx.DB) NotificationPreferencesRepository {
+	return &postgresNotificationPreferencesRepository{
+		db: db,
+	}
+}
+
+// Get returns the user's notification preferences, or ErrNotFound if the user has never set any.
+func (r *postgresNotificationPreferencesRepository) Get(userID string) (model.NotificationPreferences, error) {
+	var p model.NotificationPreferences
+	if err := r.db.Get(&p, "select * from notification_preferences where user_id = $1;", userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return p, ErrNotFound
+		}
+		return p, err
+	}
+	return p, nil
+}
+
+// ListByDigestMode lists the preferences of every user with the given digest mode.
+func (r *postgresNotificationPreferencesRepository) ListByDigestMode(mode string) ([]model.NotificationPreferences, error) {
+	var pp []model.NotificationPreferences
+	if err := r.db.Select(&pp, "select * from notification_preferences where digest_mode = $1;", mode); err != nil {
+		return nil, err
+	}
+	return pp, nil
+}
+
+func (r *postgresNotificationPreferencesRepository) Upsert(p *model.NotificationPreferences) error {
+	stmt := `
+		insert into notification_preferences (user_id, channels, quiet_hours_start, quiet_hours_end, time_zone, digest_mode)
+		values ($1, $2, $3, $4, $5, $6)
+		on conflict (user_id) do update
+			set channels = excluded.channels,
+			    quiet_hours_start = excluded.quiet_hours_start,
+			    quiet_hours_end = excluded.quiet_hours_end,
+			    time_zone = excluded.time_zone,
+			    digest_mode = excluded.digest_mode
+		returning created_at, updated_at;`
+
+	return r.db.Get(p, stmt, p.UserID, p.Channels, p.QuietHoursStart, p.QuietHoursEnd, p.TimeZone, p.DigestMode)
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"paws/internal/database/model"
+	"paws/internal/notification"
+)
+
+// TestCountAnonymousSightings runs against the database given by DATABASE_TEST_URL, which must have been migrated,
+// when it is set. It checks each spotted pet notification created for an anonymous user is counted for the pet.
+func TestCountAnonymousSightings(t *testing.T) {
+	url := os.Getenv("DATABASE_TEST_URL")
+	if url == "" {
+		t.Skip("DATABASE_TEST_URL is not set")
+	}
+	db, err := sqlx.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pets := NewPetRepository(db)
+	pet := model.Pet{UserID: "user_" + uuid.NewString(), Name: "Rex"}
+	if err := pets.Create(&pet); err != nil {
+		t.Fatalf("creating pet: %v", err)
+	}
+	defer func() {
+		_, _ = db.Exec("delete from notifications where user_id = $1;", pet.UserID)
+		_ = pets.Delete(pet.ID)
+	}()
+
+	notifications := NewNotificationRepository(db)
+	const sightings = 6
+	for i := range sightings {
+		n, err := notification.New(pet.UserID, pet.ID, notification.SpottedPetDetail{
+			IsAnonymous: true,
+			PetName:     pet.Name,
+			PetID:       pet.ID,
+			SpotterID:   uuid.NewString(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := notifications.Create(&n); err != nil {
+			t.Fatalf("creating notification %d: %v", i+1, err)
+		}
+
+		stored, err := notifications.Get(n.ID)
+		if err != nil {
+			t.Fatalf("getting notification %d: %v", i+1, err)
+		}
+		if stored.PetID != pet.ID {
+			t.Errorf("notification %d pet_id = %v, want %v", i+1, stored.PetID, pet.ID)
+		}
+	}
+
+	count, err := notifications.CountAnonymousSightings(pet.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("CountAnonymousSightings: %v", err)
+	}
+	if count != sightings {
+		t.Errorf("CountAnonymousSightings = %d, want %d", count, sightings)
+	}
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"paws/internal/database/model"
+)
+
+type OutboxRepository interface {
+	Enqueue(topic, key string, payload any) error
+	EnqueueAt(topic, key string, payload any, at time.Time) error
+	Claim(limit int, lease time.Duration) ([]model.OutboxEvent, error)
+	MarkDelivered(id int64) error
+	MarkFailed(id int64, runErr error, nextAttemptAt *time.Time) error
+	DeleteDeliveredBefore(cutoff time.Time) (int64, error)
+}
+
+type postgresOutboxRepository struct {
+	db *sqlx.DB
+}
+
+func NewOutboxRepository(db *sqlx.DB) OutboxRepository {
+	return &postgresOutboxRepository{
+		db: db,
+	}
+}
+
+// enqueue writes the event to the outbox using the given transaction, so that it is only raised if the change
+// raising it is committed. Events with a non-empty key which has already been enqueued are ignored.
+func enqueue(e sqlx.Execer, topic, key string, payload any) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshalling %s event: %w", topic, err)
+	}
+
+	stmt := `
+		insert into outbox (topic, key, payload)
+		values ($1, nullif($2, ''), $3)
+		on conflict (key) do nothing;`
+	_, err = e.Exec(stmt, topic, key, payloadJSON)
+	return err
+}
+
+// Enqueue writes the event to the outbox outside any transaction.
+func (r *postgresOutboxRepository) Enqueue(topic, key string, payload any) error {
+	return enqueue(r.db, topic, key, payload)
+}
+
+// EnqueueAt writes the event to the outbox outside any transaction, deferring it until the given time.
+// Events with a non-empty key which has already been enqueued are ignored.
+func (r *postgresOutboxRepository) EnqueueAt(topic, key string, payload any, at time.Time) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshalling %s event: %w", topic, err)
+	}
+
+	stmt := `
+		insert into outbox (topic, key, payload, next_attempt_at)
+		values ($1, nullif($2, ''), $3, $4)
+		on conflict (key) do nothing;`
+	_, err = r.db.Exec(stmt, topic, key, payloadJSON, at)
+	return err
+}
+
+// Claim returns up to limit pending events which are due, incrementing their attempts and leasing them so that
+// they are not claimed again until the lease expires. Events which are not marked delivered or failed before
+// the lease expires, such as when the worker stops, are claimed again.
+func (r *postgresOutboxRepository) Claim(limit int, lease time.Duration) ([]model.OutboxEvent, error) {
+	stmt := `
+		update outbox
+		set attempts = attempts + 1,
+		    next_attempt_at = now() + make_interval(secs => $2)
+		where id in (
+			select id from outbox
+			where status = 'pending'
+			  and next_attempt_at <= now()
+			order by id
+			limit $1
+			for update skip locked)
+		returning *;`
+
+	var events []model.OutboxEvent
+	if err := r.db.Select(&events, stmt, limit, lease.Seconds()); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *postgresOutboxRepository) MarkDelivered(id int64) error {
+	stmt := "update outbox set status = 'delivered', delivered_at = now(), last_error = null where id = $1;"
+	_, err := r.db.Exec(stmt, id)
+	return err
+}
+
+// MarkFailed records the error, scheduling the event to be retried at nextAttemptAt.
+// The event is dead lettered if nextAttemptAt is nil.
+func (r *postgresOutboxRepository) MarkFailed(id int64, runErr error, nextAttemptAt *time.Time) error {
+	stmt := `
+		update outbox
+		set last_error = $2,
+		    status = case when $3::timestamptz is null then 'dead' else 'pending' end,
+		    next_attempt_at = coalesce($3, next_attempt_at)
+		where id = $1;`
+	_, err := r.db.Exec(stmt, id, runErr.Error(), nextAttemptAt)
+	return err
+}
+
+// DeleteDeliveredBefore deletes every event delivered before the cutoff, returning the number deleted.
+func (r *postgresOutboxRepository) DeleteDeliveredBefore(cutoff time.Time) (int64, error) {
+	res, err := r.db.Exec("delete from outbox where status = 'delivered' and delivered_at < $1;", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
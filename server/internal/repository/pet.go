@@ -16,6 +16,8 @@ type PetRepository interface {
 	Create(pet *model.Pet) error
 	Update(pet *model.Pet) error
 	Delete(id uuid.UUID) error
+	// ListIDs lists the IDs of every pet.
+	ListIDs() ([]uuid.UUID, error)
 }
 
 type postgresPetRepository struct {
@@ -31,7 +33,7 @@ func NewPetRepository(db *sqlx.DB) PetRepository {
 func (r *postgresPetRepository) Get(id uuid.UUID) (model.Pet, error) {
 	stmt := `
 		select id, user_id, name, coalesce(tags, '{}')::jsonb as tags, 
-		       dob, avatar_uri, blurb, created_at, updated_at, coalesce(type, $2) as type
+		       dob, avatar_uri, blurb, status, created_at, updated_at, coalesce(type, $2) as type
     	from pets
     	where id = $1;`
 
@@ -48,7 +50,7 @@ func (r *postgresPetRepository) Get(id uuid.UUID) (model.Pet, error) {
 func (r *postgresPetRepository) List(userID string) ([]model.Pet, error) {
 	stmt := `
 		select id, user_id, name, coalesce(tags, '{}')::jsonb as tags, 
-		       dob, avatar_uri, blurb, created_at, updated_at, coalesce(type, $2) as type
+		       dob, avatar_uri, blurb, status, created_at, updated_at, coalesce(type, $2) as type
 		from pets
 		where user_id = $1;`
 
@@ -63,7 +65,7 @@ func (r *postgresPetRepository) Create(p *model.Pet) error {
 	stmt := `
 		insert into pets (user_id, name, type, dob) 
 		values ($1, $2, $3, $4) 
-		returning id, created_at, updated_at;`
+		returning id, status, created_at, updated_at;`
 
 	if err := r.db.Get(p, stmt, p.UserID, p.Name, p.Type, p.DOB); err != nil {
 		return err
@@ -71,6 +73,7 @@ func (r *postgresPetRepository) Create(p *model.Pet) error {
 	return nil
 }
 
+// Update updates the pet, raising a pet.status_changed event in the same transaction if its status changed.
 func (r *postgresPetRepository) Update(p *model.Pet) error {
 	stmt := `
 		update pets set 
@@ -79,15 +82,37 @@ func (r *postgresPetRepository) Update(p *model.Pet) error {
 		    dob = $3,
 		    type = $4,
 		    blurb = $5,
-		    avatar_uri = $6
-		where id = $7
-		returning *, coalesce(type, $8) as type;`
+		    avatar_uri = $6,
+		    status = $7
+		where id = $8
+		returning *, coalesce(type, $9) as type;`
 
 	if p.UserID == "" {
 		return errors.New("userId is required")
 	}
 
-	return r.db.Get(p, stmt, p.Name, p.Tags, p.DOB, p.Type, p.Blurb, p.AvatarURI, p.ID, string(response.PetTypeUnknown))
+	return withTx(r.db, func(tx *sqlx.Tx) error {
+		var previousStatus string
+		if err := tx.Get(&previousStatus, "select status from pets where id = $1 for update;", p.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		err := tx.Get(p, stmt, p.Name, p.Tags, p.DOB, p.Type, p.Blurb, p.AvatarURI, p.Status, p.ID, string(response.PetTypeUnknown))
+		if err != nil {
+			return err
+		}
+		if p.Status == previousStatus {
+			return nil
+		}
+		return enqueue(tx, model.OutboxTopicPetStatusChanged, "", model.PetStatusChangedEvent{
+			PetID:          p.ID,
+			PreviousStatus: previousStatus,
+			Status:         p.Status,
+		})
+	})
 }
 
 func (r *postgresPetRepository) Delete(id uuid.UUID) error {
@@ -97,3 +122,11 @@ func (r *postgresPetRepository) Delete(id uuid.UUID) error {
 	}
 	return nil
 }
+
+func (r *postgresPetRepository) ListIDs() ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0)
+	if err := r.db.Select(&ids, "select id from pets;"); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"paws/internal/database/model"
+)
+
+type PetPhotoRepository interface {
+	Get(petID uuid.UUID, id int64) (model.PetPhoto, error)
+	// List lists the photos of the pet in order of their position.
+	List(petID uuid.UUID) ([]model.PetPhoto, error)
+	// ListByPets lists the photos of each of the pets in order of their position, keyed by pet ID.
+	ListByPets(petIDs []uuid.UUID) (map[uuid.UUID][]model.PetPhoto, error)
+	// Create creates the photo after the existing photos of the pet.
+	Create(p *model.PetPhoto) error
+	Update(p *model.PetPhoto) error
+	// Delete deletes the photo, clearing the avatar of the pet if it was the primary photo.
+	Delete(petID uuid.UUID, id int64) error
+	// SetPrimary makes the photo the avatar of the pet. Private photos cannot be made primary.
+	SetPrimary(petID uuid.UUID, id int64) error
+	// ListBlobPaths lists the blob paths of every photo.
+	ListBlobPaths() ([]string, error)
+}
+
+type postgresPetPhotoRepository struct {
+	db *sqlx.DB
+}
+
+func NewPetPhotoRepository(db *sqlx.DB) PetPhotoRepository {
+	return &postgresPetPhotoRepository{
+		db: db,
+	}
+}
+
+func (r *postgresPetPhotoRepository) Get(petID uuid.UUID, id int64) (model.PetPhoto, error) {
+	stmt := `
+		select id, pet_id, blob_path, caption, position, private, created_at
+		from pet_photos
+		where id = $1 and pet_id = $2;`
+
+	var p model.PetPhoto
+	if err := r.db.Get(&p, stmt, id, petID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return p, ErrNotFound
+		}
+		return p, err
+	}
+	return p, nil
+}
+
+func (r *postgresPetPhotoRepository) List(petID uuid.UUID) ([]model.PetPhoto, error) {
+	photos, err := r.ListByPets([]uuid.UUID{petID})
+	if err != nil {
+		return nil, err
+	}
+	if pp, ok := photos[petID]; ok {
+		return pp, nil
+	}
+	return make([]model.PetPhoto, 0), nil
+}
+
+func (r *postgresPetPhotoRepository) ListByPets(petIDs []uuid.UUID) (map[uuid.UUID][]model.PetPhoto, error) {
+	stmt := `
+		select id, pet_id, blob_path, caption, position, private, created_at
+		from pet_photos
+		where pet_id = any($1)
+		order by pet_id, position, id;`
+
+	ids := make([]string, len(petIDs))
+	for i, id := range petIDs {
+		ids[i] = id.String()
+	}
+
+	var pp []model.PetPhoto
+	if err := r.db.Select(&pp, stmt, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	photos := make(map[uuid.UUID][]model.PetPhoto, len(petIDs))
+	for _, p := range pp {
+		photos[p.PetID] = append(photos[p.PetID], p)
+	}
+	return photos, nil
+}
+
+func (r *postgresPetPhotoRepository) Create(p *model.PetPhoto) error {
+	stmt := `
+		insert into pet_photos (pet_id, blob_path, caption, private, position)
+		values ($1, $2, $3, $4, (select coalesce(max(position) + 1, 0) from pet_photos where pet_id = $1))
+		returning id, position, created_at;`
+
+	return r.db.Get(p, stmt, p.PetID, p.BlobPath, p.Caption, p.Private)
+}
+
+func (r *postgresPetPhotoRepository) Update(p *model.PetPhoto) error {
+	stmt := "update pet_photos set caption = $1, position = $2, private = $3 where id = $4 and pet_id = $5;"
+	return execAffectingOne(r.db, stmt, p.Caption, p.Position, p.Private, p.ID, p.PetID)
+}
+
+func (r *postgresPetPhotoRepository) Delete(petID uuid.UUID, id int64) error {
+	return withTx(r.db, func(tx *sqlx.Tx) error {
+		var blobPath string
+		err := tx.Get(&blobPath, "delete from pet_photos where id = $1 and pet_id = $2 returning blob_path;", id, petID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		_, err = tx.Exec("update pets set avatar_uri = null where id = $1 and avatar_uri = $2;", petID, blobPath)
+		return err
+	})
+}
+
+func (r *postgresPetPhotoRepository) SetPrimary(petID uuid.UUID, id int64) error {
+	stmt := `
+		update pets set avatar_uri = ph.blob_path
+		from pet_photos ph
+		where pets.id = $1 and ph.pet_id = pets.id and ph.id = $2 and not ph.private;`
+
+	return execAffectingOne(r.db, stmt, petID, id)
+}
+
+func (r *postgresPetPhotoRepository) ListBlobPaths() ([]string, error) {
+	paths := make([]string, 0)
+	if err := r.db.Select(&paths, "select blob_path from pet_photos;"); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
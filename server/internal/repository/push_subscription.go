@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"paws/internal/database/model"
+)
+
+type PushSubscriptionRepository interface {
+	List(userID string) ([]model.PushSubscription, error)
+	Upsert(s *model.PushSubscription) error
+	Delete(userID, endpoint string) error
+	DeleteByEndpoint(endpoint string) error
+}
+
+type postgresPushSubscriptionRepository struct {
+	db *sqlx.DB
+}
+
+func NewPushSubscriptionRepository(db *sqlx.DB) PushSubscriptionRepository {
+	return &postgresPushSubscriptionRepository{
+		db: db,
+	}
+}
+
+func (r *postgresPushSubscriptionRepository) List(userID string) ([]model.PushSubscription, error) {
+	var ss []model.PushSubscription
+	if err := r.db.Select(&ss, "select * from push_subscriptions where user_id = $1;", userID); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+// Upsert creates the subscription, replacing any existing subscription with the same endpoint.
+// Browsers may re-use an endpoint for a different user after signing out, so the user is also replaced.
+func (r *postgresPushSubscriptionRepository) Upsert(s *model.PushSubscription) error {
+	stmt := `
+		insert into push_subscriptions (user_id, endpoint, p256dh, auth, user_agent)
+		values ($1, $2, $3, $4, $5)
+		on conflict (endpoint) do update
+			set user_id = excluded.user_id,
+			    p256dh = excluded.p256dh,
+			    auth = excluded.auth,
+			    user_agent = excluded.user_agent
+		returning id, created_at;`
+
+	return r.db.Get(s, stmt, s.UserID, s.Endpoint, s.P256dh, s.Auth, s.UserAgent)
+}
+
+func (r *postgresPushSubscriptionRepository) Delete(userID, endpoint string) error {
+	result, err := r.db.Exec("delete from push_subscriptions where user_id = $1 and endpoint = $2;", userID, endpoint)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresPushSubscriptionRepository) DeleteByEndpoint(endpoint string) error {
+	_, err := r.db.Exec("delete from push_subscriptions where endpoint = $1;", endpoint)
+	return err
+}
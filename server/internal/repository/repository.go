@@ -12,17 +12,58 @@ var (
 )
 
 type Repositories struct {
-	PetRepository          PetRepository
-	NotificationRepository NotificationRepository
-	ConversationRepository ConversationRepository
-	UserRepository         UserRepository
+	PetRepository                     PetRepository
+	NotificationRepository            NotificationRepository
+	ConversationRepository            ConversationRepository
+	UserRepository                    UserRepository
+	PushSubscriptionRepository        PushSubscriptionRepository
+	NotificationPreferencesRepository NotificationPreferencesRepository
+	JobRunRepository                  JobRunRepository
+	OutboxRepository                  OutboxRepository
+	PetPhotoRepository                PetPhotoRepository
 }
 
 func NewRepositories(db *sqlx.DB) *Repositories {
 	return &Repositories{
-		PetRepository:          NewPetRepository(db),
-		NotificationRepository: NewNotificationRepository(db),
-		ConversationRepository: NewConversationsRepository(db),
-		UserRepository:         NewUserRepository(db),
+		PetRepository:                     NewPetRepository(db),
+		NotificationRepository:            NewNotificationRepository(db),
+		ConversationRepository:            NewConversationsRepository(db),
+		UserRepository:                    NewUserRepository(db),
+		PushSubscriptionRepository:        NewPushSubscriptionRepository(db),
+		NotificationPreferencesRepository: NewNotificationPreferencesRepository(db),
+		JobRunRepository:                  NewJobRunRepository(db),
+		OutboxRepository:                  NewOutboxRepository(db),
+		PetPhotoRepository:                NewPetPhotoRepository(db),
 	}
 }
+
+// withTx runs fn in a transaction which is committed if fn returns nil and rolled back otherwise.
+func withTx(db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// execAffectingOne executes the statement, returning ErrNotFound if no rows were affected.
+func execAffectingOne(db sqlx.Execer, stmt string, args ...any) error {
+	res, err := db.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
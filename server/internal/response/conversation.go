@@ -1,6 +1,9 @@
 package response
 
 import (
+	"html"
+	"strings"
+
 	"github.com/google/uuid"
 	"paws/internal/database/model"
 	"time"
@@ -10,6 +13,7 @@ func NewConversationFromModel(m model.Conversation) Conversation {
 	return Conversation{
 		ID:                     m.ID,
 		Identifier:             m.Identifier,
+		Kind:                   m.Kind,
 		PrimaryParticipantID:   m.PrimaryParticipantID,
 		SecondaryParticipantID: m.SecondaryParticipantID,
 		LastMessageAt:          m.LastMessageAt,
@@ -20,6 +24,7 @@ func NewConversationFromModel(m model.Conversation) Conversation {
 type Conversation struct {
 	ID                     int64      `json:"id"`
 	Identifier             uuid.UUID  `json:"identifier"`
+	Kind                   string     `json:"kind"`
 	PrimaryParticipantID   string     `json:"primaryParticipantId"`
 	SecondaryParticipantID string     `json:"secondaryParticipantId"`
 	LastMessageAt          *time.Time `json:"lastMessageAt"`
@@ -47,3 +52,32 @@ type Message struct {
 	CreatedAt      time.Time  `json:"createdAt"`
 	ReadAt         *time.Time `json:"readAt"`
 }
+
+// MessageSearchResult is a single message matching a conversation search.
+// The Snippet is an excerpt of the message text with the matching terms wrapped in <mark> tags.
+type MessageSearchResult struct {
+	MessageID      int64     `json:"messageId"`
+	ConversationID int64     `json:"conversationId"`
+	Identifier     uuid.UUID `json:"identifier"`
+	SenderID       string    `json:"senderId"`
+	Snippet        string    `json:"snippet"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func NewMessageSearchResultFromModel(m model.MessageSearchResult) MessageSearchResult {
+	return MessageSearchResult{
+		MessageID:      m.MessageID,
+		ConversationID: m.ConversationID,
+		Identifier:     m.Identifier,
+		SenderID:       m.SenderID,
+		Snippet:        escapeSnippet(m.Snippet),
+		CreatedAt:      m.CreatedAt,
+	}
+}
+
+// escapeSnippet HTML escapes the snippet text, retaining only the <mark> tags used for highlighting.
+func escapeSnippet(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, "&lt;mark&gt;", "<mark>")
+	return strings.ReplaceAll(escaped, "&lt;/mark&gt;", "</mark>")
+}
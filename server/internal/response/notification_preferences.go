@@ -0,0 +1,165 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"paws/internal/database/model"
+	"time"
+)
+
+type DigestMode string
+
+const (
+	DigestModeOff    DigestMode = "off"
+	DigestModeDaily  DigestMode = "daily"
+	DigestModeWeekly DigestMode = "weekly"
+)
+
+const quietHoursLayout = "15:04"
+
+// QuietHours is a daily period, in the user's time zone, during which notifications are held until the period ends.
+// The period wraps over midnight when End is before Start.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// NotificationPreferences determine how notifications are delivered to a user.
+type NotificationPreferences struct {
+	// Channels maps each notification type to whether each delivery channel is enabled for that type.
+	// Any type or channel not present is enabled.
+	Channels   map[NotificationType]map[string]bool `json:"channels"`
+	QuietHours *QuietHours                          `json:"quietHours"`
+	TimeZone   string                               `json:"timeZone"`
+	// Digest, when not off, collects emails into a periodic digest rather than sending them immediately.
+	Digest DigestMode `json:"digest"`
+}
+
+// DefaultNotificationPreferences returns the preferences for users who have not set any,
+// where every notification is delivered immediately on every channel.
+func DefaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{
+		Channels: make(map[NotificationType]map[string]bool),
+		TimeZone: "UTC",
+		Digest:   DigestModeOff,
+	}
+}
+
+func NewNotificationPreferencesFromModel(m model.NotificationPreferences) NotificationPreferences {
+	p := DefaultNotificationPreferences()
+	if err := json.Unmarshal(m.Channels, &p.Channels); err != nil || p.Channels == nil {
+		p.Channels = make(map[NotificationType]map[string]bool)
+	}
+	if m.QuietHoursStart != nil && m.QuietHoursEnd != nil {
+		p.QuietHours = &QuietHours{
+			Start: *m.QuietHoursStart,
+			End:   *m.QuietHoursEnd,
+		}
+	}
+	if m.TimeZone != "" {
+		p.TimeZone = m.TimeZone
+	}
+	if m.DigestMode != "" {
+		p.Digest = DigestMode(m.DigestMode)
+	}
+	return p
+}
+
+// Validate returns an error if the time zone, quiet hours or digest mode are invalid.
+func (p NotificationPreferences) Validate() error {
+	if _, err := time.LoadLocation(p.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", p.TimeZone)
+	}
+	if p.QuietHours != nil {
+		if !validQuietHoursTime(p.QuietHours.Start) {
+			return fmt.Errorf("invalid quiet hours start %q, expected HH:MM", p.QuietHours.Start)
+		}
+		if !validQuietHoursTime(p.QuietHours.End) {
+			return fmt.Errorf("invalid quiet hours end %q, expected HH:MM", p.QuietHours.End)
+		}
+	}
+	switch p.Digest {
+	case DigestModeOff, DigestModeDaily, DigestModeWeekly:
+	default:
+		return fmt.Errorf("invalid digest mode %q", p.Digest)
+	}
+	return nil
+}
+
+// validQuietHoursTime reports whether s is a time of day in the HH:MM form the database requires.
+// The length is checked as time.Parse also accepts a single digit hour, such as 9:00.
+func validQuietHoursTime(s string) bool {
+	if len(s) != len(quietHoursLayout) {
+		return false
+	}
+	_, err := time.Parse(quietHoursLayout, s)
+	return err == nil
+}
+
+// ChannelEnabled returns true if notifications of the type should be delivered on the channel.
+func (p NotificationPreferences) ChannelEnabled(t NotificationType, channel string) bool {
+	enabled, ok := p.Channels[t][channel]
+	return !ok || enabled
+}
+
+// QuietHoursEnd returns when the user's quiet hours end if the time falls within them.
+// Returns false if the time is outside quiet hours.
+func (p NotificationPreferences) QuietHoursEnd(t time.Time) (time.Time, bool) {
+	if p.QuietHours == nil {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start, err := time.Parse(quietHoursLayout, p.QuietHours.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := time.Parse(quietHoursLayout, p.QuietHours.End)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	var inQuietHours bool
+	if startMinute <= endMinute {
+		inQuietHours = minute >= startMinute && minute < endMinute
+	} else {
+		inQuietHours = minute >= startMinute || minute < endMinute
+	}
+	if !inQuietHours {
+		return time.Time{}, false
+	}
+
+	// Quiet hours end at the next occurrence of the end time, which is tomorrow if they wrap over midnight.
+	ends := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+	if !ends.After(local) {
+		ends = time.Date(local.Year(), local.Month(), local.Day()+1, end.Hour(), end.Minute(), 0, 0, loc)
+	}
+	return ends, true
+}
+
+// ToModel converts the preferences to the model for the given user.
+func (p NotificationPreferences) ToModel(userID string) (model.NotificationPreferences, error) {
+	channels, err := json.Marshal(p.Channels)
+	if err != nil {
+		return model.NotificationPreferences{}, err
+	}
+
+	m := model.NotificationPreferences{
+		UserID:     userID,
+		Channels:   channels,
+		TimeZone:   p.TimeZone,
+		DigestMode: string(p.Digest),
+	}
+	if p.QuietHours != nil {
+		m.QuietHoursStart = &p.QuietHours.Start
+		m.QuietHoursEnd = &p.QuietHours.End
+	}
+	return m, nil
+}
@@ -38,10 +38,26 @@ type Pet struct {
 	DOB       *time.Time `json:"dob"`
 	AvatarURI *string    `json:"avatar"`
 	Blurb     *string    `json:"blurb"`
+	Status    string     `json:"status"`
+	Photos    []PetPhoto `json:"photos"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 }
 
+type PetPhoto struct {
+	ID       int64   `json:"id"`
+	Caption  *string `json:"caption"`
+	Position int     `json:"position"`
+	// Primary is true for the photo used as the avatar of the pet.
+	Primary bool `json:"primary"`
+	// Private photos are only shown to the owner of the pet.
+	Private bool `json:"private"`
+	// URLs are the signed URLs of each rendition of a private photo, keyed by rendition name.
+	// Public photos are served by the photo endpoint instead.
+	URLs      map[string]string `json:"urls,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
 func NewPetFromModel(m *model.Pet) Pet {
 	var tags PetTags
 	if err := json.Unmarshal(m.Tags, &tags); err != nil {
@@ -57,6 +73,8 @@ func NewPetFromModel(m *model.Pet) Pet {
 		DOB:       m.DOB,
 		AvatarURI: m.AvatarURI,
 		Blurb:     m.Blurb,
+		Status:    m.Status,
+		Photos:    make([]PetPhoto, 0),
 		CreatedAt: m.CreatedAt,
 		UpdatedAt: m.UpdatedAt,
 	}
@@ -64,6 +82,27 @@ func NewPetFromModel(m *model.Pet) Pet {
 	return p
 }
 
+// WithPhotos returns the pet with the given photos, which should be in order of their position.
+func (p Pet) WithPhotos(photos []model.PetPhoto) Pet {
+	p.Photos = make([]PetPhoto, len(photos))
+	for i, photo := range photos {
+		p.Photos[i] = NewPetPhotoFromModel(photo, p.AvatarURI)
+	}
+	return p
+}
+
+// NewPetPhotoFromModel creates the response for the photo of a pet with the given avatar URI.
+func NewPetPhotoFromModel(m model.PetPhoto, avatarURI *string) PetPhoto {
+	return PetPhoto{
+		ID:        m.ID,
+		Caption:   m.Caption,
+		Position:  m.Position,
+		Primary:   avatarURI != nil && *avatarURI == m.BlobPath,
+		Private:   m.Private,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
 type PetTags map[string]string
 
 func NewPetTags(j json.RawMessage) PetTags {
@@ -22,6 +22,9 @@ func (u User) PrimaryEmailAddress() string {
 	if len(u.EmailAddresses) == 0 {
 		return ""
 	}
+	if u.PrimaryEmailAddressID == nil {
+		return u.EmailAddresses[0].EmailAddress
+	}
 	for _, address := range u.EmailAddresses {
 		if address.ID == *u.PrimaryEmailAddressID {
 			return address.EmailAddress
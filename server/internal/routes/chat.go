@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+	"paws/internal/auth"
 	"paws/pkg/chat"
 )
 
@@ -22,14 +23,41 @@ func NewChatHandler(manager *chat.Manager, logger *slog.Logger) *ChatHandler {
 }
 
 func (h *ChatHandler) RegisterRoutes(mux *http.ServeMux, mf MiddlewareFunc) {
-	mux.HandleFunc("GET /room", h.HandleRoom)
+	mux.HandleFunc("GET /room", withQueryToken(mf(h.HandleRoom)))
 }
 
+// withQueryToken copies the session token from the token query parameter to the Authorization header,
+// as browsers cannot set headers on the request opening a WebSocket.
+func withQueryToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next(w, r)
+	}
+}
+
+// HandleRoom connects the participant to the room given by the r query parameter over a WebSocket.
+// Signed in users join as themselves; the pid query parameter is only accepted for them if it is their own ID.
+// Anonymous users join with the anonymous user ID given by pid, which must be a UUID so that it cannot
+// be used to join as a signed in user.
 func (h *ChatHandler) HandleRoom(w http.ResponseWriter, r *http.Request) {
 	participantID := r.URL.Query().Get("pid")
-	if participantID == "" {
-		http.Error(w, "Missing required parameter pid", http.StatusBadRequest)
-		return
+	if user := auth.GetUserFromContext(r.Context()); user.Authenticated {
+		if participantID != "" && participantID != user.ID {
+			http.Error(w, "Participant ID does not match the signed in user", http.StatusForbidden)
+			return
+		}
+		participantID = user.ID
+	} else {
+		if participantID == "" {
+			http.Error(w, "Missing required parameter pid", http.StatusBadRequest)
+			return
+		}
+		if _, err := uuid.Parse(participantID); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
 	}
 	roomID := r.URL.Query().Get("r")
 	if roomID == "" {
@@ -44,13 +72,21 @@ func (h *ChatHandler) HandleRoom(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Retrieve or create a new Room for the given Room ID.
-	room, err := h.manager.GetOrCreateRoom(roomIdentifier, participantID)
+	// Group rooms must already exist, as only the pet owner may create the group conversation.
+	var room *chat.Room
+	if r.URL.Query().Get("kind") == "group" {
+		room, err = h.manager.GetGroupRoom(roomIdentifier, participantID)
+	} else {
+		room, err = h.manager.GetOrCreateRoom(roomIdentifier, participantID)
+	}
 	if err != nil {
 		if errors.Is(err, chat.ErrUnauthorized) {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	room.ServeWS(w, r)
+	room.ServeWS(w, r, participantID)
 }
@@ -11,17 +11,23 @@ import (
 	"paws/internal/database/model"
 	"paws/internal/repository"
 	"paws/internal/response"
+	"paws/pkg/chat"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func NewConversationHandler(
 	conversationRepo repository.ConversationRepository,
 	petRepo repository.PetRepository,
 	userRepo repository.UserRepository,
+	chatManager *chat.Manager,
 	logger *slog.Logger) *ConversationHandler {
 	return &ConversationHandler{
 		ConversationRepo: conversationRepo,
 		PetRepository:    petRepo,
 		UserRepo:         userRepo,
+		ChatManager:      chatManager,
 		Logger:           logger,
 	}
 }
@@ -30,13 +36,21 @@ type ConversationHandler struct {
 	PetRepository    repository.PetRepository
 	ConversationRepo repository.ConversationRepository
 	UserRepo         repository.UserRepository
-	Logger           *slog.Logger
+	// ChatManager is used to disconnect participants removed from group conversations from the live chat.
+	ChatManager *chat.Manager
+	Logger      *slog.Logger
 }
 
 func (h *ConversationHandler) RegisterRoutes(mux *http.ServeMux, mf MiddlewareFunc) {
 	mux.HandleFunc("GET /api/v1/conversations", mf(h.ListConversations))
+	mux.HandleFunc("GET /api/v1/conversations/search", mf(h.SearchConversations))
 	mux.HandleFunc("GET /api/v1/conversations/{identifier}", mf(h.GetConversationByIdentifier))
+	mux.HandleFunc("GET /api/v1/conversations/{identifier}/export", mf(h.ExportConversation))
 	mux.HandleFunc("POST /api/v1/conversations", mf(h.CreateIfNotExists))
+	mux.HandleFunc("POST /api/v1/conversations/{identifier}/group", mf(h.CreateGroupConversation))
+	mux.HandleFunc("GET /api/v1/conversations/{identifier}/group", mf(h.GetGroupConversation))
+	mux.HandleFunc("POST /api/v1/conversations/{identifier}/group/participants", mf(h.AddGroupParticipant))
+	mux.HandleFunc("DELETE /api/v1/conversations/{identifier}/group/participants/{participantId}", mf(h.RemoveGroupParticipant))
 }
 
 type ConversationPetDetail struct {
@@ -136,6 +150,51 @@ func (h *ConversationHandler) ListConversations(w http.ResponseWriter, r *http.R
 	response.JSON(w, resp)
 }
 
+const (
+	defaultSearchLimit = 25
+	maxSearchLimit     = 100
+)
+
+// SearchConversations performs a full-text search over the messages in the current participant's conversations.
+// The query is provided by the q parameter and the number of results can be limited with the limit parameter.
+func (h *ConversationHandler) SearchConversations(w http.ResponseWriter, r *http.Request) {
+	participantID, err := getParticipantIDFromRequest(r)
+	if err != nil {
+		h.Logger.Error("failed to determine participant ID", "error", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "missing required parameter q", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, maxSearchLimit)
+	}
+
+	results, err := h.ConversationRepo.SearchMessages(participantID, query, limit)
+	if err != nil {
+		h.Logger.Error("failed to search conversations", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]response.MessageSearchResult, len(results))
+	for i, result := range results {
+		resp[i] = response.NewMessageSearchResultFromModel(result)
+	}
+	response.JSON(w, resp)
+}
+
 func (h *ConversationHandler) GetConversationByIdentifier(w http.ResponseWriter, r *http.Request) {
 	currentParticipantID, err := getParticipantIDFromRequest(r)
 	if err != nil {
@@ -182,6 +241,85 @@ func (h *ConversationHandler) GetConversationByIdentifier(w http.ResponseWriter,
 	response.JSON(w, conversation)
 }
 
+// ExportConversation streams the full message history of a conversation as a transcript.
+// The format is determined by the format parameter and may be json (default), txt or html.
+// Either participant of the conversation may export it.
+func (h *ConversationHandler) ExportConversation(w http.ResponseWriter, r *http.Request) {
+	currentParticipantID, err := getParticipantIDFromRequest(r)
+	if err != nil {
+		h.Logger.Error("failed to determine participant ID", "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	identifier, err := uuid.Parse(r.PathValue("identifier"))
+	if err != nil {
+		http.Error(w, "invalid identifier", http.StatusBadRequest)
+		return
+	}
+
+	format, ok := NewExportFormat(r.URL.Query().Get("format"))
+	if !ok {
+		http.Error(w, "invalid format, expected json, txt or html", http.StatusBadRequest)
+		return
+	}
+
+	conversationModel, err := h.ConversationRepo.Get(identifier, currentParticipantID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "conversation not found", http.StatusNotFound)
+			return
+		}
+		h.Logger.Error("failed to determine conversation", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	petLookup := h.getPetLookup(conversationModel.PrimaryParticipantID)
+	petDetail, petFound := petLookup[conversationModel.Identifier]
+	participant, otherParticipant := h.getParticipantsForConversation(currentParticipantID, *conversationModel, petLookup)
+
+	title := otherParticipant.Name
+	if petFound {
+		title = fmt.Sprintf("%s - %s", otherParticipant.Name, petDetail.Name)
+	}
+
+	participantNames := map[string]string{
+		participant.ID:      participant.Name,
+		otherParticipant.ID: otherParticipant.Name,
+	}
+
+	filename := fmt.Sprintf("conversation-%s.%s", conversationModel.Identifier, format)
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	transcript := NewTranscriptWriter(w, format)
+	header := TranscriptHeader{
+		Identifier:   conversationModel.Identifier,
+		Title:        title,
+		Participants: []ConversationParticipant{participant, otherParticipant},
+		CreatedAt:    conversationModel.CreatedAt,
+		ExportedAt:   time.Now(),
+	}
+	if err := transcript.WriteHeader(header); err != nil {
+		h.Logger.Error("failed to write transcript header", "error", err)
+		return
+	}
+
+	// The response has already started at this point, so errors can only be logged.
+	err = h.ConversationRepo.StreamMessages(conversationModel.ID, func(m model.Message) error {
+		return transcript.WriteMessage(NewTranscriptMessage(m, participantNames[m.SenderID]))
+	})
+	if err != nil {
+		h.Logger.Error("failed to stream conversation messages", "conversation", conversationModel.ID, "error", err)
+		return
+	}
+
+	if err := transcript.WriteFooter(); err != nil {
+		h.Logger.Error("failed to write transcript footer", "error", err)
+	}
+}
+
 func (h *ConversationHandler) getParticipantsForConversation(
 	currentParticipantID string,
 	conversation model.Conversation,
@@ -195,6 +333,14 @@ func (h *ConversationHandler) getParticipantsForConversation(
 		h.Logger.Error("pet details not found", "conversation_id", conversation.Identifier)
 	}
 
+	// Group conversations have any number of other participants, which are listed separately.
+	if conversation.IsGroup() {
+		participant.ID = currentParticipantID
+		participant.Name = "You"
+		otherParticipant.Name = "Search party"
+		return participant, otherParticipant
+	}
+
 	// Fetch secondary participant name
 	secondaryParticipantName := "Anonymous"
 	secondaryParticipant, err := h.UserRepo.GetAnonymousUser(conversation.SecondaryParticipantID)
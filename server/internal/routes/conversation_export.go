@@ -0,0 +1,214 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"paws/internal/database/model"
+)
+
+type ExportFormat string
+
+const (
+	ExportFormatJSON ExportFormat = "json"
+	ExportFormatText ExportFormat = "txt"
+	ExportFormatHTML ExportFormat = "html"
+)
+
+// NewExportFormat returns the ExportFormat for the given format query value, defaulting to JSON when empty.
+func NewExportFormat(f string) (ExportFormat, bool) {
+	switch f {
+	case "", "json":
+		return ExportFormatJSON, true
+	case "txt":
+		return ExportFormatText, true
+	case "html":
+		return ExportFormatHTML, true
+	default:
+		return "", false
+	}
+}
+
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatText:
+		return "text/plain; charset=utf-8"
+	case ExportFormatHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+type TranscriptHeader struct {
+	Identifier   uuid.UUID                 `json:"identifier"`
+	Title        string                    `json:"title"`
+	Participants []ConversationParticipant `json:"participants"`
+	CreatedAt    time.Time                 `json:"createdAt"`
+	ExportedAt   time.Time                 `json:"exportedAt"`
+}
+
+type TranscriptMessage struct {
+	ID            int64      `json:"id"`
+	SenderID      string     `json:"senderId"`
+	SenderName    string     `json:"senderName"`
+	Text          string     `json:"text"`
+	EmojiReaction *string    `json:"emojiReaction"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ReadAt        *time.Time `json:"readAt"`
+}
+
+func NewTranscriptMessage(m model.Message, senderName string) TranscriptMessage {
+	return TranscriptMessage{
+		ID:            m.ID,
+		SenderID:      m.SenderID,
+		SenderName:    senderName,
+		Text:          m.Text,
+		EmojiReaction: m.EmojiReaction,
+		CreatedAt:     m.CreatedAt,
+		ReadAt:        m.ReadAt,
+	}
+}
+
+// TranscriptWriter writes a conversation transcript in a specific format.
+// The header is written first, followed by each message in turn, and finally the footer.
+type TranscriptWriter interface {
+	WriteHeader(h TranscriptHeader) error
+	WriteMessage(m TranscriptMessage) error
+	WriteFooter() error
+}
+
+func NewTranscriptWriter(w io.Writer, format ExportFormat) TranscriptWriter {
+	switch format {
+	case ExportFormatText:
+		return &textTranscriptWriter{w: w}
+	case ExportFormatHTML:
+		return &htmlTranscriptWriter{w: w}
+	default:
+		return &jsonTranscriptWriter{w: w}
+	}
+}
+
+const transcriptTimeFormat = "2006-01-02 15:04:05 MST"
+
+// jsonTranscriptWriter writes the transcript as a single JSON object with the messages in a messages array.
+// The array is written incrementally so the full history does not need to be held in memory.
+type jsonTranscriptWriter struct {
+	w            io.Writer
+	messageCount int
+}
+
+func (t *jsonTranscriptWriter) WriteHeader(h TranscriptHeader) error {
+	header, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	// Open the header object back up so that the messages can be appended to it.
+	if _, err := t.w.Write(header[:len(header)-1]); err != nil {
+		return err
+	}
+	_, err = io.WriteString(t.w, `,"messages":[`)
+	return err
+}
+
+func (t *jsonTranscriptWriter) WriteMessage(m TranscriptMessage) error {
+	if t.messageCount > 0 {
+		if _, err := io.WriteString(t.w, ","); err != nil {
+			return err
+		}
+	}
+	t.messageCount++
+	return json.NewEncoder(t.w).Encode(m)
+}
+
+func (t *jsonTranscriptWriter) WriteFooter() error {
+	_, err := io.WriteString(t.w, "]}\n")
+	return err
+}
+
+type textTranscriptWriter struct {
+	w io.Writer
+}
+
+func (t *textTranscriptWriter) WriteHeader(h TranscriptHeader) error {
+	if _, err := fmt.Fprintf(t.w, "Conversation: %s\n", h.Title); err != nil {
+		return err
+	}
+	for _, p := range h.Participants {
+		if _, err := fmt.Fprintf(t.w, "Participant: %s (%s)\n", p.Name, p.ID); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(t.w, "Started: %s\nExported: %s\n\n",
+		h.CreatedAt.UTC().Format(transcriptTimeFormat),
+		h.ExportedAt.UTC().Format(transcriptTimeFormat))
+	return err
+}
+
+func (t *textTranscriptWriter) WriteMessage(m TranscriptMessage) error {
+	if _, err := fmt.Fprintf(t.w, "[%s] %s: %s\n", m.CreatedAt.UTC().Format(transcriptTimeFormat), m.SenderName, m.Text); err != nil {
+		return err
+	}
+	if m.EmojiReaction != nil {
+		if _, err := fmt.Fprintf(t.w, "    Reaction: %s\n", *m.EmojiReaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *textTranscriptWriter) WriteFooter() error {
+	return nil
+}
+
+var htmlTranscriptTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"timestamp": func(t time.Time) string { return t.UTC().Format(transcriptTimeFormat) },
+}).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48rem; margin: 2rem auto; }
+.message { margin: 0.5rem 0; }
+.meta { color: #666; font-size: 0.85rem; }
+.text { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+{{range .Participants}}<li>{{.Name}} ({{.ID}})</li>
+{{end}}</ul>
+<p class="meta">Started {{timestamp .CreatedAt}} &middot; Exported {{timestamp .ExportedAt}}</p>
+<hr>
+{{end}}
+{{define "message"}}<div class="message" id="message-{{.ID}}">
+<div class="meta">{{timestamp .CreatedAt}} &middot; {{.SenderName}}{{with .EmojiReaction}} &middot; {{.}}{{end}}</div>
+<div class="text">{{.Text}}</div>
+</div>
+{{end}}
+{{define "footer"}}</body>
+</html>
+{{end}}`))
+
+type htmlTranscriptWriter struct {
+	w io.Writer
+}
+
+func (t *htmlTranscriptWriter) WriteHeader(h TranscriptHeader) error {
+	return htmlTranscriptTemplate.ExecuteTemplate(t.w, "header", h)
+}
+
+func (t *htmlTranscriptWriter) WriteMessage(m TranscriptMessage) error {
+	return htmlTranscriptTemplate.ExecuteTemplate(t.w, "message", m)
+}
+
+func (t *htmlTranscriptWriter) WriteFooter() error {
+	return htmlTranscriptTemplate.ExecuteTemplate(t.w, "footer", nil)
+}
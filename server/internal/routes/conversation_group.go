@@ -0,0 +1,276 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"paws/internal/auth"
+	"paws/internal/database/model"
+	"paws/internal/repository"
+	"paws/internal/response"
+)
+
+type GroupConversationMember struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+type GroupConversationResponse struct {
+	response.Conversation
+	Pet     ConversationPetDetail     `json:"pet"`
+	Title   string                    `json:"title"`
+	Members []GroupConversationMember `json:"members"`
+}
+
+type AddGroupParticipantRequest struct {
+	ParticipantID string `json:"participantId" validate:"required"`
+	Role          string `json:"role" validate:"required"`
+}
+
+// CreateGroupConversation creates the group conversation for a pet if it does not already exist.
+// Only the owner of the pet may create the group conversation.
+func (h *ConversationHandler) CreateGroupConversation(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	identifier, err := uuid.Parse(r.PathValue("identifier"))
+	if err != nil {
+		http.Error(w, "invalid identifier", http.StatusBadRequest)
+		return
+	}
+
+	conversationModel, err := h.ConversationRepo.GetOrCreateGroup(identifier, user.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "pet not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrNotAuthorized) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.Logger.Error("failed to create group conversation", "identifier", identifier, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeGroupConversation(w, user.ID, conversationModel)
+}
+
+// GetGroupConversation returns the group conversation for a pet along with its members.
+// Only members of the group conversation may view it.
+func (h *ConversationHandler) GetGroupConversation(w http.ResponseWriter, r *http.Request) {
+	currentParticipantID, err := getParticipantIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	identifier, err := uuid.Parse(r.PathValue("identifier"))
+	if err != nil {
+		http.Error(w, "invalid identifier", http.StatusBadRequest)
+		return
+	}
+
+	conversationModel, ok := h.getGroupConversation(w, identifier, currentParticipantID)
+	if !ok {
+		return
+	}
+	h.writeGroupConversation(w, currentParticipantID, conversationModel)
+}
+
+// AddGroupParticipant invites a finder or volunteer to the group conversation.
+// Only the owner of the group conversation may invite participants.
+func (h *ConversationHandler) AddGroupParticipant(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	identifier, err := uuid.Parse(r.PathValue("identifier"))
+	if err != nil {
+		http.Error(w, "invalid identifier", http.StatusBadRequest)
+		return
+	}
+
+	var req AddGroupParticipantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.ParticipantID == "" {
+		http.Error(w, "participantId is required", http.StatusBadRequest)
+		return
+	}
+	if req.Role != model.ParticipantRoleFinder && req.Role != model.ParticipantRoleVolunteer {
+		http.Error(w, "invalid role, expected finder or volunteer", http.StatusBadRequest)
+		return
+	}
+
+	conversationModel, ok := h.getGroupConversation(w, identifier, user.ID)
+	if !ok {
+		return
+	}
+	if conversationModel.PrimaryParticipantID != user.ID {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if req.ParticipantID == user.ID {
+		http.Error(w, "the owner is already a member", http.StatusBadRequest)
+		return
+	}
+
+	exists, err := h.participantExists(req.ParticipantID)
+	if err != nil {
+		h.Logger.Error("failed to look up participant", "participant", req.ParticipantID, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "participant not found", http.StatusNotFound)
+		return
+	}
+
+	participant := &model.ConversationParticipant{
+		ConversationID: conversationModel.ID,
+		ParticipantID:  req.ParticipantID,
+		Role:           req.Role,
+		InvitedBy:      &user.ID,
+	}
+	if err := h.ConversationRepo.AddParticipant(participant); err != nil {
+		h.Logger.Error("failed to add group participant", "conversation", conversationModel.ID, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeGroupConversation(w, user.ID, conversationModel)
+}
+
+// RemoveGroupParticipant removes a participant from the group conversation.
+// The owner may remove any other participant, and any participant may remove themselves to leave the group.
+func (h *ConversationHandler) RemoveGroupParticipant(w http.ResponseWriter, r *http.Request) {
+	currentParticipantID, err := getParticipantIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	identifier, err := uuid.Parse(r.PathValue("identifier"))
+	if err != nil {
+		http.Error(w, "invalid identifier", http.StatusBadRequest)
+		return
+	}
+	participantID := r.PathValue("participantId")
+
+	conversationModel, ok := h.getGroupConversation(w, identifier, currentParticipantID)
+	if !ok {
+		return
+	}
+
+	isOwner := conversationModel.PrimaryParticipantID == currentParticipantID
+	if !isOwner && participantID != currentParticipantID {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if participantID == conversationModel.PrimaryParticipantID {
+		http.Error(w, "the owner cannot be removed from the group", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ConversationRepo.RemoveParticipant(conversationModel.ID, participantID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "participant not found", http.StatusNotFound)
+			return
+		}
+		h.Logger.Error("failed to remove group participant", "conversation", conversationModel.ID, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.ChatManager.DisconnectParticipant(conversationModel.ID, conversationModel.Identifier, participantID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// participantExists reports whether the participant is a registered or anonymous user.
+func (h *ConversationHandler) participantExists(participantID string) (bool, error) {
+	if _, err := h.UserRepo.GetUser(participantID); !errors.Is(err, repository.ErrNotFound) {
+		return err == nil, err
+	}
+	if _, err := h.UserRepo.GetAnonymousUser(participantID); !errors.Is(err, repository.ErrNotFound) {
+		return err == nil, err
+	}
+	return false, nil
+}
+
+// getGroupConversation gets the group conversation the participant is a member of, writing an error response
+// and returning false if it cannot be found.
+func (h *ConversationHandler) getGroupConversation(w http.ResponseWriter, identifier uuid.UUID, participantID string) (*model.Conversation, bool) {
+	conversationModel, err := h.ConversationRepo.GetGroup(identifier, participantID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "conversation not found", http.StatusNotFound)
+			return nil, false
+		}
+		h.Logger.Error("failed to get group conversation", "identifier", identifier, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return conversationModel, true
+}
+
+func (h *ConversationHandler) writeGroupConversation(w http.ResponseWriter, currentParticipantID string, conversationModel *model.Conversation) {
+	participants, err := h.ConversationRepo.ListParticipants(conversationModel.ID)
+	if err != nil {
+		h.Logger.Error("failed to list group participants", "conversation", conversationModel.ID, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	petLookup := h.getPetLookup(conversationModel.PrimaryParticipantID)
+	petDetail := petLookup[conversationModel.Identifier]
+
+	members := make([]GroupConversationMember, len(participants))
+	for i, p := range participants {
+		members[i] = GroupConversationMember{
+			ID:       p.ParticipantID,
+			Name:     h.getGroupMemberName(currentParticipantID, p),
+			Role:     p.Role,
+			JoinedAt: p.JoinedAt,
+		}
+	}
+
+	response.JSON(w, GroupConversationResponse{
+		Conversation: response.NewConversationFromModel(*conversationModel),
+		Pet:          petDetail,
+		Title:        fmt.Sprintf("Search party - %s", petDetail.Name),
+		Members:      members,
+	})
+}
+
+// getGroupMemberName resolves the display name of a group member.
+// Finders and volunteers may be either anonymous or registered users.
+func (h *ConversationHandler) getGroupMemberName(currentParticipantID string, p model.ConversationParticipant) string {
+	if p.ParticipantID == currentParticipantID {
+		return "You"
+	}
+	if p.Role == model.ParticipantRoleOwner {
+		return "Owner"
+	}
+
+	if anonymousUser, err := h.UserRepo.GetAnonymousUser(p.ParticipantID); err == nil && anonymousUser.Name != "" {
+		return anonymousUser.Name
+	}
+	if user, err := h.UserRepo.GetUser(p.ParticipantID); err == nil && user.FirstName != nil && *user.FirstName != "" {
+		return *user.FirstName
+	}
+	return "Anonymous"
+}
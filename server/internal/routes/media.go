@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"paws/pkg/blobstore"
+	"paws/pkg/signedurl"
+)
+
+// mediaPathPrefix is the path MediaHandler serves blobs under.
+const mediaPathPrefix = "/api/v1/media/"
+
+func NewMediaHandler(store blobstore.BlobStore, signer *signedurl.Signer, logger *slog.Logger) *MediaHandler {
+	return &MediaHandler{
+		Store:  store,
+		Signer: signer,
+		Logger: logger,
+	}
+}
+
+// MediaHandler serves private media, such as private pet photos, from URLs signed by the API.
+// Anyone holding a signed URL may fetch the blob until the URL expires, so the API only returns them to
+// users allowed to see the media.
+type MediaHandler struct {
+	Store  blobstore.BlobStore
+	Signer *signedurl.Signer
+	Logger *slog.Logger
+}
+
+func (h *MediaHandler) RegisterRoutes(mux *http.ServeMux, mf MiddlewareFunc) {
+	mux.HandleFunc("GET "+mediaPathPrefix+"{path...}", mf(h.GetMedia))
+}
+
+// GetMedia serves the blob at the path if the URL is signed and has not expired.
+func (h *MediaHandler) GetMedia(w http.ResponseWriter, r *http.Request) {
+	path := r.PathValue("path")
+	expires, err := h.Signer.Verify(path, r.URL.Query())
+	if err != nil {
+		if errors.Is(err, signedurl.ErrExpired) {
+			http.Error(w, "url expired", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	blob, err := h.Store.Get(r.Context(), path)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotFound) || errors.Is(err, blobstore.ErrInvalidPath) {
+			http.Error(w, "media not found", http.StatusNotFound)
+			return
+		}
+		h.Logger.Error("error opening media", "path", path, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	// The response may be cached by the browser, but not by shared caches, until the URL expires.
+	maxAge := int(time.Until(expires).Seconds())
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+	serveBlob(w, r, blob)
+}
+
+// mediaURL returns the signed URL, relative to the API, MediaHandler serves the blob at the path from.
+func mediaURL(signer *signedurl.Signer, path string) string {
+	u := url.URL{Path: mediaPathPrefix + path, RawQuery: signer.Sign(path).Encode()}
+	return u.String()
+}
+
+// serveBlob serves the blob, responding to conditional and range requests.
+func serveBlob(w http.ResponseWriter, r *http.Request, blob *blobstore.Blob) {
+	w.Header().Set("Content-Type", blob.ContentType)
+	if blob.SHA256 != "" {
+		w.Header().Set("ETag", `"`+blob.SHA256+`"`)
+	}
+	http.ServeContent(w, r, "", blob.UpdatedAt, blob)
+}
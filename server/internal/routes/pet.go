@@ -3,33 +3,34 @@ package routes
 import (
 	"encoding/json"
 	"errors"
-	"io"
 	"log/slog"
 	"net/http"
 	"paws/internal/database/model"
+	"paws/internal/notification"
 	"paws/internal/response"
 	"time"
 
 	"github.com/google/uuid"
 	"paws/internal/auth"
 	"paws/internal/repository"
-	"paws/pkg/blight"
+	"paws/pkg/blobstore"
+	"paws/pkg/signedurl"
 )
 
 func NewPetsHandler(
 	notificationRepo repository.NotificationRepository,
 	petRepo repository.PetRepository,
+	photoRepo repository.PetPhotoRepository,
+	avatars blobstore.BlobStore,
+	mediaSigner *signedurl.Signer,
 	logger *slog.Logger,
 ) *PetsHandler {
-	b, err := blight.New("./avatars.db")
-	if err != nil {
-		panic(err)
-	}
-
 	return &PetsHandler{
 		NotificationRepo: notificationRepo,
 		PetRepo:          petRepo,
-		Blight:           b,
+		PhotoRepo:        photoRepo,
+		Avatars:          avatars,
+		MediaSigner:      mediaSigner,
 		Logger:           logger,
 	}
 }
@@ -37,8 +38,11 @@ func NewPetsHandler(
 type PetsHandler struct {
 	NotificationRepo repository.NotificationRepository
 	PetRepo          repository.PetRepository
-	Blight           *blight.Client
-	Logger           *slog.Logger
+	PhotoRepo        repository.PetPhotoRepository
+	Avatars          blobstore.BlobStore
+	// MediaSigner signs the URLs private photos are served from.
+	MediaSigner *signedurl.Signer
+	Logger      *slog.Logger
 }
 
 func (h *PetsHandler) RegisterRoutes(mux *http.ServeMux, mf MiddlewareFunc) {
@@ -51,6 +55,12 @@ func (h *PetsHandler) RegisterRoutes(mux *http.ServeMux, mf MiddlewareFunc) {
 	mux.HandleFunc("DELETE /api/v1/pets/{id}", mf(h.DeletePet))
 	mux.HandleFunc("PUT /api/v1/pets/{id}/avatar", mf(h.UpdateAvatar))
 	mux.HandleFunc("GET /api/v1/pets/{id}/avatar", mf(h.GetAvatar))
+	mux.HandleFunc("DELETE /api/v1/pets/{id}/avatar", mf(h.DeleteAvatar))
+	mux.HandleFunc("GET /api/v1/pets/{id}/photos", mf(h.ListPetPhotos))
+	mux.HandleFunc("POST /api/v1/pets/{id}/photos", mf(h.AddPetPhoto))
+	mux.HandleFunc("GET /api/v1/pets/{id}/photos/{photoId}", mf(h.GetPetPhoto))
+	mux.HandleFunc("PUT /api/v1/pets/{id}/photos/{photoId}", mf(h.UpdatePetPhoto))
+	mux.HandleFunc("DELETE /api/v1/pets/{id}/photos/{photoId}", mf(h.DeletePetPhoto))
 	mux.HandleFunc("POST /api/v1/pets/{id}/alert", mf(h.CreateNotificationOnPetPageVisit))
 }
 
@@ -63,14 +73,14 @@ func (h *PetsHandler) GetPetByID(w http.ResponseWriter, r *http.Request) {
 
 	pet, err := h.PetRepo.Get(id)
 	if err != nil {
-		if errors.As(err, &repository.ErrNotFound) {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.Error(w, "pet not found", http.StatusNotFound)
 			return
 		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	response.JSON(w, response.NewPetFromModel(&pet))
+	h.writePet(w, r, &pet)
 }
 
 func (h *PetsHandler) ListPets(w http.ResponseWriter, r *http.Request) {
@@ -86,9 +96,20 @@ func (h *PetsHandler) ListPets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	petIDs := make([]uuid.UUID, len(pets))
+	for i, p := range pets {
+		petIDs[i] = p.ID
+	}
+	photos, err := h.PhotoRepo.ListByPets(petIDs)
+	if err != nil {
+		h.Logger.Error("error listing pet photos", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
 	results := make([]response.Pet, len(pets))
 	for i, p := range pets {
-		results[i] = response.NewPetFromModel(&p)
+		results[i] = h.petResponse(&p, photos[p.ID], user.ID)
 	}
 	response.JSON(w, results)
 }
@@ -148,6 +169,8 @@ type UpdatePetRequest struct {
 	Name  string     `json:"name" validate:"required"`
 	DOB   *time.Time `json:"dob"`
 	Blurb *string    `json:"blurb"`
+	// Status is either home or missing; the status is unchanged if omitted.
+	Status *string `json:"status"`
 }
 
 func (h *PetsHandler) UpdatePet(w http.ResponseWriter, r *http.Request) {
@@ -171,6 +194,10 @@ func (h *PetsHandler) UpdatePet(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid type", http.StatusBadRequest)
 		return
 	}
+	if req.Status != nil && *req.Status != model.PetStatusHome && *req.Status != model.PetStatusMissing {
+		http.Error(w, "invalid status, expected home or missing", http.StatusBadRequest)
+		return
+	}
 
 	petId, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
@@ -181,7 +208,7 @@ func (h *PetsHandler) UpdatePet(w http.ResponseWriter, r *http.Request) {
 	pet, err := h.PetRepo.Get(petId)
 	if err != nil {
 		h.Logger.Error("error getting pet", "error", err)
-		if notFound := errors.As(err, &repository.ErrNotFound); notFound {
+		if notFound := errors.Is(err, repository.ErrNotFound); notFound {
 			http.Error(w, "pet not found", http.StatusNotFound)
 			return
 		}
@@ -198,13 +225,16 @@ func (h *PetsHandler) UpdatePet(w http.ResponseWriter, r *http.Request) {
 	pet.Type = req.Type
 	pet.Blurb = req.Blurb
 	pet.DOB = req.DOB
+	if req.Status != nil {
+		pet.Status = *req.Status
+	}
 
 	if err := h.PetRepo.Update(&pet); err != nil {
 		h.Logger.Error("error updating pet", "error", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
-	response.JSON(w, response.NewPetFromModel(&pet))
+	h.writePet(w, r, &pet)
 }
 
 type NewTagRequest struct {
@@ -237,7 +267,7 @@ func (h *PetsHandler) AddTag(w http.ResponseWriter, r *http.Request) {
 
 	petModel, err := h.PetRepo.Get(petID)
 	if err != nil {
-		if errors.As(err, &repository.ErrNotFound) {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.Error(w, "pet not found", http.StatusNotFound)
 			return
 		}
@@ -264,7 +294,7 @@ func (h *PetsHandler) AddTag(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response.JSON(w, response.NewPetFromModel(&petModel))
+	h.writePet(w, r, &petModel)
 }
 
 func (h *PetsHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
@@ -283,7 +313,7 @@ func (h *PetsHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
 
 	petModel, err := h.PetRepo.Get(petID)
 	if err != nil {
-		if errors.As(err, &repository.ErrNotFound) {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.Error(w, "pet not found", http.StatusNotFound)
 			return
 		}
@@ -310,7 +340,7 @@ func (h *PetsHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
-	response.JSON(w, response.NewPetFromModel(&petModel))
+	h.writePet(w, r, &petModel)
 }
 
 func (h *PetsHandler) DeletePet(w http.ResponseWriter, r *http.Request) {
@@ -328,7 +358,7 @@ func (h *PetsHandler) DeletePet(w http.ResponseWriter, r *http.Request) {
 
 	existingPetModel, err := h.PetRepo.Get(petID)
 	if err != nil {
-		if errors.As(err, &repository.ErrNotFound) {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.Error(w, "pet not found", http.StatusNotFound)
 			return
 		}
@@ -341,65 +371,59 @@ func (h *PetsHandler) DeletePet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.PetRepo.Delete(petID); err != nil {
-		h.Logger.Error("error deleting pet", "error", err)
+	photos, err := h.PhotoRepo.List(petID)
+	if err != nil {
+		h.Logger.Error("error listing pet photos", "pet", petID, "error", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
-	response.JSON(w, response.NewPetFromModel(&existingPetModel))
-}
-
-func (h *PetsHandler) UpdateAvatar(w http.ResponseWriter, r *http.Request) {
-	user := auth.GetUserFromContext(r.Context())
-	if !user.Authenticated {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
 
-	petID, err := uuid.Parse(r.PathValue("id"))
-	if err != nil {
-		http.Error(w, "invalid pet id", http.StatusBadRequest)
+	if err := h.PetRepo.Delete(petID); err != nil {
+		h.Logger.Error("error deleting pet", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		h.Logger.Error("error parsing multipart form", "error", err)
-		http.Error(w, "unable to parse form", http.StatusBadRequest)
-		return
+	// Any blobs which cannot be deleted now are removed by the blight garbage collector.
+	prefixes := []string{petID.String()}
+	for _, p := range photos {
+		prefixes = append(prefixes, p.BlobPath)
 	}
-
-	file, _, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, "file upload error", http.StatusBadRequest)
-		return
+	for _, prefix := range prefixes {
+		if err := h.deleteImage(r.Context(), prefix); err != nil {
+			h.Logger.Error("error deleting pet images", "path", prefix, "error", err)
+		}
 	}
-	defer file.Close()
-
-	path := petID.String()
-	if err := h.Blight.Add(path, file); err != nil {
-		http.Error(w, "failed to save file", http.StatusBadRequest)
-		return
+	if err := h.Avatars.Delete(r.Context(), petID.String()); err != nil && !errors.Is(err, blobstore.ErrNotFound) {
+		h.Logger.Error("error deleting legacy avatar", "pet", petID, "error", err)
 	}
-	response.JSON(w, map[string]string{"avatar_uri": path})
+	response.JSON(w, response.NewPetFromModel(&existingPetModel))
 }
 
-func (h *PetsHandler) GetAvatar(w http.ResponseWriter, r *http.Request) {
-	id, err := uuid.Parse(r.PathValue("id"))
+// getPet gets the pet, writing an error response and returning false if it cannot be found.
+func (h *PetsHandler) getPet(w http.ResponseWriter, id uuid.UUID) (model.Pet, bool) {
+	pet, err := h.PetRepo.Get(id)
 	if err != nil {
-		http.Error(w, "invalid pet id", http.StatusBadRequest)
-		return
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "pet not found", http.StatusNotFound)
+			return pet, false
+		}
+		h.Logger.Error("error getting pet", "pet", id, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return pet, false
 	}
+	return pet, true
+}
 
-	result, err := h.Blight.Get(id.String())
+// writePet writes the pet along with the photos the user making the request may see.
+func (h *PetsHandler) writePet(w http.ResponseWriter, r *http.Request, pet *model.Pet) {
+	photos, err := h.PhotoRepo.List(pet.ID)
 	if err != nil {
+		h.Logger.Error("error listing pet photos", "pet", pet.ID, "error", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
-
-	r.Header.Set("Content-Type", "image/jpeg")
-	if _, err := io.Copy(w, result.BLOB); err != nil {
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-	}
+	response.JSON(w, h.petResponse(pet, photos, auth.GetUserFromContext(r.Context()).ID))
 }
 
 type NewAlertRequest struct {
@@ -407,10 +431,17 @@ type NewAlertRequest struct {
 	AlertingAnonymousUserId string `json:"anonymous_user_id"`
 }
 
-func (a NewAlertRequest) IsAnonymous() bool {
-	return a.AlertingAnonymousUserId != "" && a.AlertingUserId == ""
-}
+const (
+	// anonymousSpotterLimit is the most spotted pet notifications created for different anonymous users spotting
+	// a pet within anonymousSpotterWindow. Anonymous user IDs are generated by the client, so without a limit the
+	// owner could be flooded with notifications by changing the ID on every visit.
+	anonymousSpotterLimit  = 5
+	anonymousSpotterWindow = time.Hour
+)
 
+// CreateNotificationOnPetPageVisit notifies the owner that someone has spotted their pet by visiting its page.
+// Signed in users are identified by their session, and the user_id in the request is ignored; anonymous users are
+// identified by the anonymous_user_id in the request.
 func (h *PetsHandler) CreateNotificationOnPetPageVisit(w http.ResponseWriter, r *http.Request) {
 	alertCreatedResponse := func(w http.ResponseWriter, created bool) {
 		status := http.StatusOK
@@ -420,50 +451,36 @@ func (h *PetsHandler) CreateNotificationOnPetPageVisit(w http.ResponseWriter, r
 		response.WithStatus(w, status).SendJSON(map[string]bool{"alert_created": created})
 	}
 
-	processAndValidateRequest := func(r *http.Request) (NewAlertRequest, uuid.UUID, error) {
-		var req NewAlertRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			return req, uuid.Nil, err
-		}
-		if req.AlertingAnonymousUserId == "" && req.AlertingUserId == "" {
-			h.Logger.Error("cannot create notification; user_id or anonymous_user_id is required", "req", req)
-			return req, uuid.Nil, errors.New("user_id or anonymous_user_id is required")
-		}
-		petID, err := uuid.Parse(r.PathValue("id"))
-		if err != nil {
-			h.Logger.Error("error parsing petID", "petID", petID, "error", err)
-			return req, uuid.Nil, errors.New("error parsing petID")
-		}
-		return req, petID, nil
-	}
-
-	makeSpottedPetNotificationModel := func(req NewAlertRequest, pet model.Pet) (model.Notification, error) {
-		spotterName := ""
-		if !req.IsAnonymous() {
-			spotterName = "a registered user"
-		}
-
-		notificationModel, err := model.NewSpottedPetNotification(pet.UserID, model.SpottedPetNotificationDetail{
-			SpotterName: spotterName,
-			IsAnonymous: req.IsAnonymous(),
-			PetName:     pet.Name,
-			PetID:       pet.ID,
-		})
-		return notificationModel, err
-	}
-
 	user := auth.GetUserFromContext(r.Context())
 
-	req, petID, err := processAndValidateRequest(r)
+	var req NewAlertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	petID, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
-		h.Logger.Error("invalid request", "err", err)
 		http.Error(w, "invalid request", http.StatusBadRequest)
 		return
 	}
 
+	spotterID, isAnonymous := user.ID, false
+	if !user.Authenticated {
+		if req.AlertingUserId != "" {
+			// The user ID cannot be trusted without a session.
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if req.AlertingAnonymousUserId == "" {
+			http.Error(w, "anonymous_user_id is required", http.StatusBadRequest)
+			return
+		}
+		spotterID, isAnonymous = req.AlertingAnonymousUserId, true
+	}
+
 	pet, err := h.PetRepo.Get(petID)
 	if err != nil {
-		if errors.As(err, &repository.ErrNotFound) {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.Error(w, "pet not found", http.StatusNotFound)
 			return
 		}
@@ -472,28 +489,51 @@ func (h *PetsHandler) CreateNotificationOnPetPageVisit(w http.ResponseWriter, r
 		return
 	}
 
-	if pet.UserID == user.ID {
+	if pet.UserID == spotterID {
 		// Ensure the user is not creating alerts for themselves.
 		alertCreatedResponse(w, false)
 		return
 	}
 
-	notificationModel, err := makeSpottedPetNotificationModel(req, pet)
+	spotterName := "a registered user"
+	if isAnonymous {
+		spotterName = ""
+	}
+	notificationModel, err := notification.New(pet.UserID, pet.ID, notification.SpottedPetDetail{
+		SpotterName: spotterName,
+		IsAnonymous: isAnonymous,
+		PetName:     pet.Name,
+		PetID:       pet.ID,
+		SpotterID:   spotterID,
+	})
 	if err != nil {
 		h.Logger.Error("failed to create notification model", "err", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	recentlyNotified, err := h.NotificationRepo.RecentlyNotified(notificationModel)
+	suppressed, err := h.suppressDuplicateNotification(notificationModel, pet)
 	if err != nil {
 		h.Logger.Error("error determining if recently notified", "error", err)
 	}
-	if recentlyNotified {
+	if suppressed {
 		alertCreatedResponse(w, false)
 		return
 	}
 
+	if isAnonymous {
+		count, err := h.NotificationRepo.CountAnonymousSightings(pet.ID, anonymousSpotterWindow)
+		if err != nil {
+			h.Logger.Error("error counting anonymous sightings", "pet", pet.ID, "error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if count >= anonymousSpotterLimit {
+			alertCreatedResponse(w, false)
+			return
+		}
+	}
+
 	if err := h.NotificationRepo.Create(&notificationModel); err != nil {
 		h.Logger.Error("error creating notification", "error", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
@@ -502,3 +542,14 @@ func (h *PetsHandler) CreateNotificationOnPetPageVisit(w http.ResponseWriter, r
 
 	alertCreatedResponse(w, true)
 }
+
+// suppressDuplicateNotification determines if the notification about the pet duplicates a recent notification,
+// using the dedup window of the notification type for the pet's status. Duplicates are counted on the
+// existing notification.
+func (h *PetsHandler) suppressDuplicateNotification(n model.Notification, pet model.Pet) (bool, error) {
+	t, ok := notification.Lookup(n.Type)
+	if !ok || t.Dedup == nil {
+		return false, nil
+	}
+	return h.NotificationRepo.SuppressDuplicate(n, t.Dedup.WindowFor(pet.Status))
+}
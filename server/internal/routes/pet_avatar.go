@@ -0,0 +1,271 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/google/uuid"
+	"paws/internal/auth"
+	"paws/internal/response"
+	"paws/pkg/blobstore"
+	"paws/pkg/imaging"
+)
+
+// maxAvatarRequestSize is the largest avatar upload request accepted.
+const maxAvatarRequestSize = 10 << 20
+
+// avatarRenditions are the sizes avatars and photos are stored in, the first being the default.
+var avatarRenditions = []imaging.Rendition{
+	{Name: "full", MaxSize: 1600},
+	{Name: "card", MaxSize: 480},
+	{Name: "thumb", MaxSize: 128},
+}
+
+// UpdateAvatar stores the uploaded image as the avatar of the pet, resized into each of the avatarRenditions.
+// The uploaded avatar replaces any primary photo as the avatar of the pet.
+// Only the owner of the pet may update its avatar.
+func (h *PetsHandler) UpdateAvatar(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	petID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid pet id", http.StatusBadRequest)
+		return
+	}
+
+	// Ownership is checked before the upload is read, so uploads for other pets are rejected early.
+	pet, ok := h.getPet(w, petID)
+	if !ok {
+		return
+	}
+	if pet.UserID != user.ID {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// The file is read from the request body directly rather than being buffered by ParseMultipartForm.
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarRequestSize)
+	file, err := getMultipartFile(r, "file")
+	if err != nil {
+		h.Logger.Error("error reading multipart form", "error", err)
+		http.Error(w, "file upload error", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "file upload error", http.StatusBadRequest)
+		return
+	}
+
+	metadata := map[string]string{
+		"filename":    file.FileName(),
+		"uploaded_by": user.ID,
+	}
+	if err := h.storeImage(r.Context(), petID.String(), data, metadata); err != nil {
+		h.writeStoreImageError(w, petID, err)
+		return
+	}
+
+	// Avatars uploaded before renditions were introduced are stored, unprocessed, at the pet ID.
+	if err := h.Avatars.Delete(r.Context(), petID.String()); err != nil && !errors.Is(err, blobstore.ErrNotFound) {
+		h.Logger.Error("error deleting legacy avatar", "pet", petID, "error", err)
+	}
+
+	avatarURI := petID.String()
+	pet.AvatarURI = &avatarURI
+	if err := h.PetRepo.Update(&pet); err != nil {
+		h.Logger.Error("error updating pet", "pet", petID, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	response.JSON(w, map[string]string{"avatar_uri": avatarURI})
+}
+
+// DeleteAvatar deletes the uploaded avatar of the pet, leaving it without an avatar. If a primary photo is the
+// avatar, the photo is kept but is no longer primary. Only the owner of the pet may delete its avatar.
+func (h *PetsHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	petID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid pet id", http.StatusBadRequest)
+		return
+	}
+
+	pet, ok := h.getPet(w, petID)
+	if !ok {
+		return
+	}
+	if pet.UserID != user.ID {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if pet.AvatarURI != nil {
+		pet.AvatarURI = nil
+		if err := h.PetRepo.Update(&pet); err != nil {
+			h.Logger.Error("error updating pet", "pet", petID, "error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Any blobs which cannot be deleted now are removed by the blight garbage collector.
+	if err := h.deleteImage(r.Context(), petID.String()); err != nil {
+		h.Logger.Error("error deleting avatar renditions", "pet", petID, "error", err)
+	}
+	if err := h.Avatars.Delete(r.Context(), petID.String()); err != nil && !errors.Is(err, blobstore.ErrNotFound) {
+		h.Logger.Error("error deleting legacy avatar", "pet", petID, "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAvatar serves the rendition of the avatar given by the size query parameter, which defaults to full.
+// The avatar is the primary photo of the pet if one has been chosen.
+func (h *PetsHandler) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid pet id", http.StatusBadRequest)
+		return
+	}
+
+	pet, ok := h.getPet(w, id)
+	if !ok {
+		return
+	}
+
+	// The avatar is either an uploaded avatar, stored under the pet ID, or the primary photo of the pet.
+	prefix := id.String()
+	if pet.AvatarURI != nil {
+		prefix = *pet.AvatarURI
+	}
+	h.serveImage(w, r, prefix, id.String())
+}
+
+// storeImage processes the image into each of the avatarRenditions, storing them under the path prefix.
+// imaging.ErrUnsupportedFormat or imaging.ErrTooLarge is returned if the image cannot be processed.
+func (h *PetsHandler) storeImage(ctx context.Context, prefix string, data []byte, metadata map[string]string) error {
+	renditions, err := imaging.Process(data, avatarRenditions)
+	if err != nil {
+		return err
+	}
+
+	for _, rendition := range renditions {
+		opts := blobstore.PutOptions{
+			ContentType: imaging.ContentType,
+			Metadata:    maps.Clone(metadata),
+		}
+		opts.Metadata["rendition"] = rendition.Rendition.Name
+
+		path := renditionPath(prefix, rendition.Rendition.Name)
+		if err := h.Avatars.Put(ctx, path, bytes.NewReader(rendition.Data), int64(len(rendition.Data)), opts); err != nil {
+			return fmt.Errorf("error storing %s rendition: %w", rendition.Rendition.Name, err)
+		}
+	}
+	return nil
+}
+
+func (h *PetsHandler) writeStoreImageError(w http.ResponseWriter, petID uuid.UUID, err error) {
+	if errors.Is(err, imaging.ErrUnsupportedFormat) || errors.Is(err, imaging.ErrTooLarge) {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	h.Logger.Error("error storing image", "pet", petID, "error", err)
+	http.Error(w, "failed to save file", http.StatusInternalServerError)
+}
+
+// deleteImage deletes each of the renditions stored under the path prefix.
+func (h *PetsHandler) deleteImage(ctx context.Context, prefix string) error {
+	var errs []error
+	for _, rendition := range avatarRenditions {
+		err := h.Avatars.Delete(ctx, renditionPath(prefix, rendition.Name))
+		if err != nil && !errors.Is(err, blobstore.ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// serveImage serves the rendition given by the size query parameter, which defaults to full, of the image stored
+// under the path prefix. The blob at fallbackPath is served if the rendition does not exist and fallbackPath is
+// not empty.
+func (h *PetsHandler) serveImage(w http.ResponseWriter, r *http.Request, prefix, fallbackPath string) {
+	size := r.URL.Query().Get("size")
+	if size == "" {
+		size = avatarRenditions[0].Name
+	}
+	if !isAvatarRendition(size) {
+		http.Error(w, "invalid size, expected full, card or thumb", http.StatusBadRequest)
+		return
+	}
+
+	image, err := h.Avatars.Get(r.Context(), renditionPath(prefix, size))
+	if errors.Is(err, blobstore.ErrNotFound) && fallbackPath != "" {
+		image, err = h.Avatars.Get(r.Context(), fallbackPath)
+	}
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotFound) {
+			http.Error(w, "image not found", http.StatusNotFound)
+			return
+		}
+		h.Logger.Error("error opening image", "path", prefix, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer image.Close()
+
+	serveBlob(w, r, image)
+}
+
+func renditionPath(prefix, rendition string) string {
+	return prefix + "/" + rendition
+}
+
+func isAvatarRendition(name string) bool {
+	for _, r := range avatarRenditions {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getMultipartFile returns the first part of the multipart request body with the given form name.
+// The part must be read before any other part of the request body.
+func getMultipartFile(r *http.Request, name string) (*multipart.Part, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, http.ErrMissingFile
+			}
+			return nil, err
+		}
+		if part.FormName() == name && part.FileName() != "" {
+			return part, nil
+		}
+	}
+}
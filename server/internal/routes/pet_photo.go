@@ -0,0 +1,406 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"paws/internal/auth"
+	"paws/internal/database/model"
+	"paws/internal/repository"
+	"paws/internal/response"
+)
+
+const (
+	// maxPetPhotos is the most photos a pet may have.
+	maxPetPhotos = 20
+	// maxPhotoCaptionLength is the longest caption, in bytes, a photo may have.
+	maxPhotoCaptionLength = 500
+)
+
+type UpdatePetPhotoRequest struct {
+	// Caption replaces the caption of the photo; an empty caption removes it.
+	Caption *string `json:"caption"`
+	// Position moves the photo within the gallery; photos are ordered by position.
+	Position *int `json:"position"`
+	// Primary makes the photo the avatar of the pet when true.
+	Primary bool `json:"primary"`
+	// Private hides the photo from everyone but the owner of the pet when true. Private photos cannot be primary.
+	Private *bool `json:"private"`
+}
+
+// ListPetPhotos lists the photos of a pet in order of their position.
+// Private photos are only listed for the owner of the pet.
+func (h *PetsHandler) ListPetPhotos(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+
+	petID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid pet id", http.StatusBadRequest)
+		return
+	}
+
+	pet, ok := h.getPet(w, petID)
+	if !ok {
+		return
+	}
+	photos, err := h.PhotoRepo.List(petID)
+	if err != nil {
+		h.Logger.Error("error listing pet photos", "pet", petID, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	response.JSON(w, h.petResponse(&pet, photos, user.ID).Photos)
+}
+
+// AddPetPhoto adds the uploaded image, with an optional caption, to the end of the gallery of the pet.
+// The photo is private if the private field is true. Only the owner of the pet may add photos.
+func (h *PetsHandler) AddPetPhoto(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	petID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid pet id", http.StatusBadRequest)
+		return
+	}
+
+	pet, ok := h.getPet(w, petID)
+	if !ok {
+		return
+	}
+	if pet.UserID != user.ID {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	existing, err := h.PhotoRepo.List(petID)
+	if err != nil {
+		h.Logger.Error("error listing pet photos", "pet", petID, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxPetPhotos {
+		http.Error(w, fmt.Sprintf("a pet may have at most %d photos", maxPetPhotos), http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarRequestSize)
+	upload, err := readPhotoUpload(r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		h.Logger.Error("error reading multipart form", "error", err)
+		http.Error(w, "file upload error", http.StatusBadRequest)
+		return
+	}
+	if len(upload.caption) > maxPhotoCaptionLength {
+		http.Error(w, fmt.Sprintf("caption must be at most %d characters", maxPhotoCaptionLength), http.StatusBadRequest)
+		return
+	}
+
+	photo := &model.PetPhoto{
+		PetID:    petID,
+		BlobPath: petID.String() + "/photos/" + uuid.NewString(),
+		Private:  upload.private,
+	}
+	if upload.caption != "" {
+		photo.Caption = &upload.caption
+	}
+
+	metadata := map[string]string{
+		"filename":    upload.filename,
+		"uploaded_by": user.ID,
+	}
+	if err := h.storeImage(r.Context(), photo.BlobPath, upload.data, metadata); err != nil {
+		h.writeStoreImageError(w, petID, err)
+		return
+	}
+
+	if err := h.PhotoRepo.Create(photo); err != nil {
+		h.Logger.Error("error creating pet photo", "pet", petID, "error", err)
+		if err := h.deleteImage(r.Context(), photo.BlobPath); err != nil {
+			h.Logger.Error("error deleting pet photo", "path", photo.BlobPath, "error", err)
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	response.JSON(w, h.photoResponse(*photo, &pet))
+}
+
+// GetPetPhoto serves the rendition of the photo given by the size query parameter, which defaults to full.
+// Private photos are only served to the owner of the pet, and are otherwise reported as not found; they are
+// usually fetched from the signed URLs in the photo responses instead.
+func (h *PetsHandler) GetPetPhoto(w http.ResponseWriter, r *http.Request) {
+	petID, photoID, ok := parsePetPhotoPath(w, r)
+	if !ok {
+		return
+	}
+
+	photo, err := h.PhotoRepo.Get(petID, photoID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "photo not found", http.StatusNotFound)
+			return
+		}
+		h.Logger.Error("error getting pet photo", "pet", petID, "photo", photoID, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if photo.Private {
+		pet, ok := h.getPet(w, petID)
+		if !ok {
+			return
+		}
+		if pet.UserID != auth.GetUserFromContext(r.Context()).ID {
+			http.Error(w, "photo not found", http.StatusNotFound)
+			return
+		}
+	}
+	h.serveImage(w, r, photo.BlobPath, "")
+}
+
+// UpdatePetPhoto updates the caption, position or privacy of a photo, or makes it the primary photo of the pet.
+// Only the owner of the pet may update photos.
+func (h *PetsHandler) UpdatePetPhoto(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	petID, photoID, ok := parsePetPhotoPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdatePetPhotoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.Caption != nil && len(*req.Caption) > maxPhotoCaptionLength {
+		http.Error(w, fmt.Sprintf("caption must be at most %d characters", maxPhotoCaptionLength), http.StatusBadRequest)
+		return
+	}
+	if req.Position != nil && *req.Position < 0 {
+		http.Error(w, "position must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	pet, ok := h.getPet(w, petID)
+	if !ok {
+		return
+	}
+	if pet.UserID != user.ID {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	photo, err := h.PhotoRepo.Get(petID, photoID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "photo not found", http.StatusNotFound)
+			return
+		}
+		h.Logger.Error("error getting pet photo", "pet", petID, "photo", photoID, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Caption != nil {
+		photo.Caption = req.Caption
+		if *req.Caption == "" {
+			photo.Caption = nil
+		}
+	}
+	if req.Position != nil {
+		photo.Position = *req.Position
+	}
+	if req.Private != nil {
+		photo.Private = *req.Private
+	}
+	// The avatar is public, so the primary photo must be too.
+	isPrimary := req.Primary || (pet.AvatarURI != nil && *pet.AvatarURI == photo.BlobPath)
+	if photo.Private && isPrimary {
+		http.Error(w, "the primary photo cannot be private", http.StatusBadRequest)
+		return
+	}
+	if err := h.PhotoRepo.Update(&photo); err != nil {
+		h.Logger.Error("error updating pet photo", "pet", petID, "photo", photoID, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Primary {
+		if err := h.PhotoRepo.SetPrimary(petID, photoID); err != nil {
+			h.Logger.Error("error setting primary pet photo", "pet", petID, "photo", photoID, "error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		pet.AvatarURI = &photo.BlobPath
+	}
+	response.JSON(w, h.photoResponse(photo, &pet))
+}
+
+// DeletePetPhoto deletes a photo; if it was the primary photo, the pet is left without an avatar.
+// Only the owner of the pet may delete photos.
+func (h *PetsHandler) DeletePetPhoto(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	petID, photoID, ok := parsePetPhotoPath(w, r)
+	if !ok {
+		return
+	}
+
+	pet, ok := h.getPet(w, petID)
+	if !ok {
+		return
+	}
+	if pet.UserID != user.ID {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	photo, err := h.PhotoRepo.Get(petID, photoID)
+	if err == nil {
+		err = h.PhotoRepo.Delete(petID, photoID)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "photo not found", http.StatusNotFound)
+			return
+		}
+		h.Logger.Error("error deleting pet photo", "pet", petID, "photo", photoID, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.deleteImage(r.Context(), photo.BlobPath); err != nil {
+		h.Logger.Error("error deleting pet photo renditions", "path", photo.BlobPath, "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parsePetPhotoPath(w http.ResponseWriter, r *http.Request) (uuid.UUID, int64, bool) {
+	petID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid pet id", http.StatusBadRequest)
+		return uuid.Nil, 0, false
+	}
+	photoID, err := strconv.ParseInt(r.PathValue("photoId"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid photo id", http.StatusBadRequest)
+		return uuid.Nil, 0, false
+	}
+	return petID, photoID, true
+}
+
+// petResponse creates the response for the pet with the photos the user may see. Private photos are only
+// included for the owner of the pet.
+func (h *PetsHandler) petResponse(pet *model.Pet, photos []model.PetPhoto, userID string) response.Pet {
+	visible := make([]model.PetPhoto, 0, len(photos))
+	for _, p := range photos {
+		if !p.Private || pet.UserID == userID {
+			visible = append(visible, p)
+		}
+	}
+
+	res := response.NewPetFromModel(pet).WithPhotos(visible)
+	for i, p := range visible {
+		if p.Private {
+			res.Photos[i].URLs = h.renditionURLs(p.BlobPath)
+		}
+	}
+	return res
+}
+
+// photoResponse creates the response for a photo of the pet for its owner.
+func (h *PetsHandler) photoResponse(photo model.PetPhoto, pet *model.Pet) response.PetPhoto {
+	res := response.NewPetPhotoFromModel(photo, pet.AvatarURI)
+	if photo.Private {
+		res.URLs = h.renditionURLs(photo.BlobPath)
+	}
+	return res
+}
+
+// renditionURLs returns the signed media URLs of each of the renditions stored under the path prefix.
+// Private photos are served from signed URLs as browsers cannot send the credentials of the user when
+// loading images.
+func (h *PetsHandler) renditionURLs(prefix string) map[string]string {
+	urls := make(map[string]string, len(avatarRenditions))
+	for _, rendition := range avatarRenditions {
+		urls[rendition.Name] = mediaURL(h.MediaSigner, renditionPath(prefix, rendition.Name))
+	}
+	return urls
+}
+
+type photoUpload struct {
+	data     []byte
+	filename string
+	caption  string
+	private  bool
+}
+
+// readPhotoUpload reads the file, caption and private fields of the multipart request body.
+func readPhotoUpload(r *http.Request) (photoUpload, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return photoUpload{}, err
+	}
+
+	var upload photoUpload
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return photoUpload{}, err
+		}
+
+		switch part.FormName() {
+		case "file":
+			if upload.data, err = io.ReadAll(part); err != nil {
+				return photoUpload{}, err
+			}
+			upload.filename = part.FileName()
+		case "caption":
+			caption, err := io.ReadAll(io.LimitReader(part, maxPhotoCaptionLength+1))
+			if err != nil {
+				return photoUpload{}, err
+			}
+			upload.caption = string(caption)
+		case "private":
+			value, err := io.ReadAll(io.LimitReader(part, 8))
+			if err != nil {
+				return photoUpload{}, err
+			}
+			if upload.private, err = strconv.ParseBool(string(value)); err != nil {
+				return photoUpload{}, fmt.Errorf("invalid private field: %w", err)
+			}
+		}
+	}
+
+	if upload.data == nil {
+		return photoUpload{}, http.ErrMissingFile
+	}
+	return upload, nil
+}
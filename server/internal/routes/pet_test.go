@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"paws/internal/database/model"
+	"paws/internal/notification"
+	"paws/internal/repository"
+)
+
+// memoryPetRepository is a repository.PetRepository holding pets in memory. Only Get is implemented.
+type memoryPetRepository struct {
+	repository.PetRepository
+	pets map[uuid.UUID]model.Pet
+}
+
+func (r *memoryPetRepository) Get(id uuid.UUID) (model.Pet, error) {
+	pet, ok := r.pets[id]
+	if !ok {
+		return model.Pet{}, repository.ErrNotFound
+	}
+	return pet, nil
+}
+
+// memoryNotificationRepository is a repository.NotificationRepository holding notifications in memory.
+// Only the methods used when a pet is spotted are implemented, matching on the detail as the database does.
+type memoryNotificationRepository struct {
+	repository.NotificationRepository
+	notifications []model.Notification
+}
+
+func (r *memoryNotificationRepository) Create(n *model.Notification) error {
+	n.ID = int64(len(r.notifications) + 1)
+	r.notifications = append(r.notifications, *n)
+	return nil
+}
+
+func (r *memoryNotificationRepository) SuppressDuplicate(n model.Notification, _ time.Duration) (bool, error) {
+	var detail notification.SpottedPetDetail
+	if err := json.Unmarshal(n.Detail, &detail); err != nil {
+		return false, err
+	}
+	for _, m := range r.spottedPetDetails() {
+		if m.PetID == detail.PetID && m.SpotterID == detail.SpotterID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryNotificationRepository) CountAnonymousSightings(petID uuid.UUID, _ time.Duration) (int, error) {
+	count := 0
+	for _, d := range r.spottedPetDetails() {
+		if d.PetID == petID && d.IsAnonymous {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryNotificationRepository) spottedPetDetails() []notification.SpottedPetDetail {
+	var details []notification.SpottedPetDetail
+	for _, n := range r.notifications {
+		var d notification.SpottedPetDetail
+		if n.Type == notification.TypeSpottedPet && json.Unmarshal(n.Detail, &d) == nil {
+			details = append(details, d)
+		}
+	}
+	return details
+}
+
+func TestCreateNotificationOnPetPageVisitLimitsAnonymousSpotters(t *testing.T) {
+	pet := model.Pet{ID: uuid.New(), UserID: "user_owner", Name: "Rex", Status: model.PetStatusMissing}
+	notifications := &memoryNotificationRepository{}
+	h := &PetsHandler{
+		NotificationRepo: notifications,
+		PetRepo:          &memoryPetRepository{pets: map[uuid.UUID]model.Pet{pet.ID: pet}},
+		Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	visit := func(anonymousUserID string) (int, bool) {
+		body := fmt.Sprintf(`{"anonymous_user_id": %q}`, anonymousUserID)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/pets/"+pet.ID.String()+"/alert", strings.NewReader(body))
+		req.SetPathValue("id", pet.ID.String())
+		rec := httptest.NewRecorder()
+		h.CreateNotificationOnPetPageVisit(rec, req)
+
+		var resp struct {
+			AlertCreated bool `json:"alert_created"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decoding response with status %d: %v", rec.Code, err)
+		}
+		return rec.Code, resp.AlertCreated
+	}
+
+	for i := range anonymousSpotterLimit {
+		if status, created := visit(uuid.NewString()); status != http.StatusCreated || !created {
+			t.Fatalf("sighting %d = %d, alert_created %t, want %d, true", i+1, status, created, http.StatusCreated)
+		}
+	}
+	if status, created := visit(uuid.NewString()); status != http.StatusOK || created {
+		t.Errorf("sighting %d = %d, alert_created %t, want %d, false",
+			anonymousSpotterLimit+1, status, created, http.StatusOK)
+	}
+	if len(notifications.notifications) != anonymousSpotterLimit {
+		t.Errorf("created %d notifications, want %d", len(notifications.notifications), anonymousSpotterLimit)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"paws/internal/application"
 	"paws/internal/auth"
+	"strings"
 )
 
 type MiddlewareFunc func(http.HandlerFunc) http.HandlerFunc
@@ -15,17 +16,32 @@ type RouteRegister interface {
 func BuildRoutesServerMux(app *application.App) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	staticHandler := http.StripPrefix("/static/", http.FileServer(http.Dir("./static")))
-	mux.Handle("/static/", staticHandler)
+	staticHandler := http.StripPrefix("/static/", http.FileServer(http.Dir(application.StaticDir)))
+	mux.Handle("/static/", hideDotFiles(staticHandler))
 
 	logger := app.Logger
 	repos := app.Repositories
 
 	handlers := []RouteRegister{
 		NewPingPongHandler(),
-		NewUsersHandler(repos.UserRepository, repos.NotificationRepository, repos.PetRepository, logger),
-		NewPetsHandler(repos.NotificationRepository, repos.PetRepository, logger),
-		NewConversationHandler(repos.ConversationRepository, repos.PetRepository, repos.UserRepository, logger),
+		NewUsersHandler(
+			repos.UserRepository,
+			repos.NotificationRepository,
+			repos.PetRepository,
+			repos.PushSubscriptionRepository,
+			repos.NotificationPreferencesRepository,
+			app.NotificationHub,
+			app.Config.VAPID.PublicKey,
+			logger),
+		NewPetsHandler(
+			repos.NotificationRepository,
+			repos.PetRepository,
+			repos.PetPhotoRepository,
+			app.BlobStore,
+			app.MediaSigner,
+			logger),
+		NewMediaHandler(app.BlobStore, app.MediaSigner, logger),
+		NewConversationHandler(repos.ConversationRepository, repos.PetRepository, repos.UserRepository, app.ChatManager, logger),
 		NewChatHandler(app.ChatManager, logger),
 		NewWebhookHandler(app.Config.Clerk.SigningSecret, repos.UserRepository, logger),
 	}
@@ -34,7 +50,7 @@ func BuildRoutesServerMux(app *application.App) *http.ServeMux {
 		if r.Method == http.MethodOptions {
 			w.Header().Set("Access-Control-Allow-Origin", app.Config.ClientBaseURL)
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Accept, X-Requested-With, AnonymousUserId")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Accept, X-Requested-With, AnonymousUserId, Last-Event-ID")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -64,8 +80,9 @@ func corsMiddleware(next http.HandlerFunc, clientBaseURL string) http.HandlerFun
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", clientBaseURL)
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Accept, X-Requested-With, AnonymousUserId")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Accept, X-Requested-With, AnonymousUserId, Last-Event-ID")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -76,6 +93,18 @@ func corsMiddleware(next http.HandlerFunc, clientBaseURL string) http.HandlerFun
 	}
 }
 
+// hideDotFiles responds with not found for paths with a segment starting with a dot, such as the .meta directory
+// the filesystem blob store keeps the attributes of blobs in.
+func hideDotFiles(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/.") {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // recoverMiddleware handles recovering from a panic.
 func recoverMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
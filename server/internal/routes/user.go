@@ -2,21 +2,33 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"log/slog"
 	"net/http"
 	"paws/internal/auth"
 	"paws/internal/database/model"
+	"paws/internal/notify"
 	"paws/internal/repository"
 	"paws/internal/response"
+	"paws/pkg/pubsub"
+	"paws/pkg/webpush"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 type UsersHandler struct {
 	UserRepo         repository.UserRepository
 	NotificationRepo repository.NotificationRepository
 	PetRepo          repository.PetRepository
+	PushRepo         repository.PushSubscriptionRepository
+	PreferencesRepo  repository.NotificationPreferencesRepository
+	NotificationHub  *pubsub.Hub[model.Notification]
+	VAPIDPublicKey   string
 	Logger           *slog.Logger
 }
 
@@ -24,20 +36,37 @@ func NewUsersHandler(
 	userRepo repository.UserRepository,
 	notificationRepo repository.NotificationRepository,
 	petRepo repository.PetRepository,
+	pushRepo repository.PushSubscriptionRepository,
+	preferencesRepo repository.NotificationPreferencesRepository,
+	notificationHub *pubsub.Hub[model.Notification],
+	vapidPublicKey string,
 	logger *slog.Logger,
 ) *UsersHandler {
 	return &UsersHandler{
 		UserRepo:         userRepo,
 		NotificationRepo: notificationRepo,
 		PetRepo:          petRepo,
+		PushRepo:         pushRepo,
+		PreferencesRepo:  preferencesRepo,
+		NotificationHub:  notificationHub,
+		VAPIDPublicKey:   vapidPublicKey,
 		Logger:           logger,
 	}
 }
 
 func (h *UsersHandler) RegisterRoutes(mux *http.ServeMux, mf MiddlewareFunc) {
 	mux.HandleFunc("GET /api/v1/user/notifications", mf(h.ListNotifications))
+	mux.HandleFunc("GET /api/v1/user/notifications/stream", mf(h.StreamNotifications))
+	mux.HandleFunc("GET /api/v1/user/notifications/unseen-count", mf(h.CountUnseenNotifications))
 	mux.HandleFunc("POST /api/v1/user/notifications/read-all", mf(h.MarkAllNotificationsAsSeen))
+	mux.HandleFunc("POST /api/v1/user/notifications/{id}/seen", mf(h.MarkNotificationAsSeen))
+	mux.HandleFunc("DELETE /api/v1/user/notifications/{id}", mf(h.DeleteNotification))
 	mux.HandleFunc("PUT /api/v1/user/anonymous/{id}", mf(h.UpdateAnonymousUser))
+	mux.HandleFunc("GET /api/v1/user/notification-preferences", mf(h.GetNotificationPreferences))
+	mux.HandleFunc("PUT /api/v1/user/notification-preferences", mf(h.UpdateNotificationPreferences))
+	mux.HandleFunc("GET /api/v1/user/push-subscriptions/vapid-public-key", mf(h.GetVAPIDPublicKey))
+	mux.HandleFunc("POST /api/v1/user/push-subscriptions", mf(h.CreatePushSubscription))
+	mux.HandleFunc("DELETE /api/v1/user/push-subscriptions", mf(h.DeletePushSubscription))
 }
 
 type UpdateAnonymousUserRequest struct {
@@ -72,6 +101,15 @@ func (h *UsersHandler) UpdateAnonymousUser(w http.ResponseWriter, r *http.Reques
 	response.JSON(w, response.NewAnonymousUserFromModel(userModel))
 }
 
+const (
+	defaultNotificationPageSize = 50
+	maxNotificationPageSize     = 100
+)
+
+// ListNotifications lists the user's notifications, newest first, and unseen=true lists only unseen notifications.
+// Every notification is listed unless the limit or before parameter is given, in which case a page of up to limit
+// notifications is listed. If there are more notifications, the X-Next-Cursor header holds the value of the before
+// parameter used to request the next page.
 func (h *UsersHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
 	user := auth.GetUserFromContext(r.Context())
 	if !user.Authenticated {
@@ -79,11 +117,45 @@ func (h *UsersHandler) ListNotifications(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	notificationModels, err := h.NotificationRepo.List(user.ID)
+	opts := repository.ListNotificationsOptions{
+		UnseenOnly: r.URL.Query().Get("unseen") == "true",
+	}
+	// Clients which predate pagination request neither parameter and expect every notification.
+	paginate := r.URL.Query().Has("limit") || r.URL.Query().Has("before")
+	if paginate {
+		opts.Limit = defaultNotificationPageSize
+	}
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		opts.Limit = min(limit, maxNotificationPageSize)
+	}
+	if beforeParam := r.URL.Query().Get("before"); beforeParam != "" {
+		before, err := strconv.ParseInt(beforeParam, 10, 64)
+		if err != nil || before < 1 {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		opts.Before = before
+	}
+
+	// One more notification than requested is listed to determine if there is a next page.
+	pageSize := opts.Limit
+	if paginate {
+		opts.Limit++
+	}
+	notificationModels, err := h.NotificationRepo.List(user.ID, opts)
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	if paginate && len(notificationModels) > pageSize {
+		notificationModels = notificationModels[:pageSize]
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(notificationModels[pageSize-1].ID, 10))
+	}
 
 	pets, err := h.PetRepo.List(user.ID)
 	petsLookup := make(map[uuid.UUID]string)
@@ -110,6 +182,175 @@ func (h *UsersHandler) ListNotifications(w http.ResponseWriter, r *http.Request)
 	response.JSON(w, notifications)
 }
 
+func (h *UsersHandler) CountUnseenNotifications(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := h.NotificationRepo.CountUnseen(user.ID)
+	if err != nil {
+		h.Logger.Error("error counting unseen notifications", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	response.JSON(w, map[string]int{"count": count})
+}
+
+func (h *UsersHandler) MarkNotificationAsSeen(w http.ResponseWriter, r *http.Request) {
+	h.updateNotification(w, r, h.NotificationRepo.MarkSeen)
+}
+
+func (h *UsersHandler) DeleteNotification(w http.ResponseWriter, r *http.Request) {
+	h.updateNotification(w, r, h.NotificationRepo.Delete)
+}
+
+// updateNotification applies the update to the notification identified in the path if it belongs to the user.
+func (h *UsersHandler) updateNotification(w http.ResponseWriter, r *http.Request, update func(userID string, id int64) error) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := parseNotificationID(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := update(user.ID, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "Notification not found", http.StatusNotFound)
+			return
+		}
+		h.Logger.Error("error updating notification", "notification", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseNotificationID parses the ID of a notification, which may be either the numeric database ID
+// or the ID returned in the notification response, which is prefixed with the notification type.
+func parseNotificationID(s string) (int64, error) {
+	if i := strings.LastIndex(s, "_"); i >= 0 {
+		s = s[i+1:]
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+const (
+	notificationStreamHeartbeatInterval = 25 * time.Second
+	// notificationStreamReplayOverlap is how long before the last event notifications are replayed from when the
+	// client reconnects, covering notifications which committed after others with greater IDs.
+	notificationStreamReplayOverlap = time.Minute
+)
+
+// StreamNotifications pushes new notifications to the user as Server-Sent Events as they are created.
+// Each event ID is the notification ID; if the client reconnects with a Last-Event-ID header (or lastEventId
+// parameter), any notifications created or updated since that event are sent before new notifications. Events
+// from a short overlap before the last event are replayed too, so the client may receive a notification it has
+// already received. A notification updated in place, such as a coalesced new_message notification, is sent again.
+// The stream is ended if the client falls behind and notifications are dropped by the hub, so that the client
+// reconnects and the dropped notifications are sent from the database.
+func (h *UsersHandler) StreamNotifications(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	var afterID int64
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		afterID = id
+	}
+
+	// Subscribe before replaying missed notifications so none are lost in between.
+	notifications, unsubscribe := h.NotificationHub.Subscribe(user.ID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// sent holds the updated_at of each notification sent on this stream. Notification IDs may commit out of
+	// order, so a notification is skipped only if this version of it has already been sent.
+	sent := make(map[int64]time.Time)
+	send := func(m model.Notification) bool {
+		if sentAt, ok := sent[m.ID]; ok && !m.UpdatedAt.After(sentAt) {
+			return true
+		}
+		n, ok := response.NewNotificationFromModel(m)
+		if !ok {
+			h.Logger.Error("error parsing notification model", "model", m)
+			return true
+		}
+		data, err := json.Marshal(n)
+		if err != nil {
+			h.Logger.Error("error marshalling notification", "error", err)
+			return true
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: notification\ndata: %s\n\n", m.ID, data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		sent[m.ID] = m.UpdatedAt
+		return true
+	}
+
+	if lastEventID != "" {
+		missed, err := h.NotificationRepo.ListSince(user.ID, afterID, notificationStreamReplayOverlap)
+		if err != nil {
+			h.Logger.Error("error listing missed notifications", "error", err)
+		}
+		for _, m := range missed {
+			if !send(m) {
+				return
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(notificationStreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case m, ok := <-notifications:
+			// The channel is closed once notifications have been dropped; they are sent from the database
+			// when the client reconnects with the last event ID.
+			if !ok || !send(m) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
 func (h *UsersHandler) MarkAllNotificationsAsSeen(w http.ResponseWriter, r *http.Request) {
 	user := auth.GetUserFromContext(r.Context())
 	if !user.Authenticated {
@@ -124,6 +365,158 @@ func (h *UsersHandler) MarkAllNotificationsAsSeen(w http.ResponseWriter, r *http
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *UsersHandler) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	preferencesModel, err := h.PreferencesRepo.Get(user.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			response.JSON(w, response.DefaultNotificationPreferences())
+			return
+		}
+		h.Logger.Error("error getting notification preferences", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	response.JSON(w, response.NewNotificationPreferencesFromModel(preferencesModel))
+}
+
+func (h *UsersHandler) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	req := response.DefaultNotificationPreferences()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Channels == nil {
+		req.Channels = make(map[response.NotificationType]map[string]bool)
+	}
+	for notificationType, channels := range req.Channels {
+		if response.NewNotificationType(string(notificationType)) == response.UnknownNotification {
+			http.Error(w, fmt.Sprintf("Unknown notification type %q", notificationType), http.StatusBadRequest)
+			return
+		}
+		for channel := range channels {
+			if !slices.Contains(notify.Channels, channel) {
+				http.Error(w, fmt.Sprintf("Unknown channel %q", channel), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	preferencesModel, err := req.ToModel(user.ID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.PreferencesRepo.Upsert(&preferencesModel); err != nil {
+		h.Logger.Error("error updating notification preferences", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	response.JSON(w, response.NewNotificationPreferencesFromModel(preferencesModel))
+}
+
+// GetVAPIDPublicKey returns the public key browsers must use as the applicationServerKey when subscribing to push.
+func (h *UsersHandler) GetVAPIDPublicKey(w http.ResponseWriter, r *http.Request) {
+	if h.VAPIDPublicKey == "" {
+		http.Error(w, "Push notifications are not enabled", http.StatusNotFound)
+		return
+	}
+	response.JSON(w, map[string]string{"publicKey": h.VAPIDPublicKey})
+}
+
+// PushSubscriptionRequest matches the JSON serialization of a browser PushSubscription.
+type PushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+func (h *UsersHandler) CreatePushSubscription(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req PushSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if err := webpush.ValidateEndpoint(req.Endpoint); err != nil {
+		http.Error(w, "Invalid endpoint", http.StatusBadRequest)
+		return
+	}
+	if req.Keys.P256dh == "" || req.Keys.Auth == "" {
+		http.Error(w, "Subscription keys are required", http.StatusBadRequest)
+		return
+	}
+
+	var userAgent *string
+	if ua := r.UserAgent(); ua != "" {
+		userAgent = &ua
+	}
+	subscription := &model.PushSubscription{
+		UserID:    user.ID,
+		Endpoint:  req.Endpoint,
+		P256dh:    req.Keys.P256dh,
+		Auth:      req.Keys.Auth,
+		UserAgent: userAgent,
+	}
+	if err := h.PushRepo.Upsert(subscription); err != nil {
+		h.Logger.Error("error creating push subscription", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+type DeletePushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint"`
+}
+
+func (h *UsersHandler) DeletePushSubscription(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !user.Authenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req DeletePushSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Endpoint == "" {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.PushRepo.Delete(user.ID, req.Endpoint); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "Subscription not found", http.StatusNotFound)
+			return
+		}
+		h.Logger.Error("error deleting push subscription", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func validateRequest(req UpdateAnonymousUserRequest) error {
 	// Perform necessary validation, e.g., ensuring the name is not empty
 	if req.Name == "" {
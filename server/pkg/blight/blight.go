@@ -2,10 +2,18 @@ package blight
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -14,109 +22,576 @@ import (
 var (
 	ErrCreatingDatabase = errors.New("error creating database")
 	ErrBlobNotFound     = errors.New("blob not found")
+	ErrSizeMismatch     = errors.New("blob size does not match the expected size")
 )
 
-const addStmt = `
-	insert into blobs (path, blob) 
-	values (?, ?)
+// chunkSize is the size of the chunks blobs are stored in.
+// Blobs are read and written a chunk at a time so they never need to be held in memory in full.
+const chunkSize = 256 << 10
+
+const upsertBlobStmt = `
+	insert into blobs (path, size, content_type, sha256, metadata, content_id)
+	values (?, ?, ?, ?, ?, ?)
 	on conflict(path) do update
-		set blob = ?;`
+		set size = excluded.size,
+			content_type = excluded.content_type,
+			sha256 = excluded.sha256,
+			metadata = excluded.metadata,
+			content_id = excluded.content_id;`
+
+const getBlobStmt = `
+	select path, size, content_type, sha256, metadata, created_at, updated_at, content_id
+	from blobs
+	where path = ?;`
+
+// listBlobsStmt lists the blobs with paths starting with the given prefix.
+const listBlobsStmt = `
+	select path, size, content_type, sha256, metadata, created_at, updated_at, content_id
+	from blobs
+	where substr(path, 1, length(?1)) = ?1
+	order by path;`
+
+const getBlobContentStmt = "select content_id from blobs where path = ?;"
+
+const deleteBlobStmt = "delete from blobs where path = ? returning content_id;"
+
+// deleteBlobUnchangedSinceStmt deletes the blob only if it has not been updated since the given time,
+// formatted as CURRENT_TIMESTAMP is.
+const deleteBlobUnchangedSinceStmt = "delete from blobs where path = ? and updated_at <= ? returning content_id;"
+
+// insertContentStmt inserts an unreferenced content row, which the checksum and size are set on once
+// its chunks have been written.
+const insertContentStmt = "insert into contents (sha256, size, refcount) values ('', 0, 0);"
+
+const updateContentStmt = "update contents set sha256 = ?, size = ? where id = ?;"
+
+// findContentStmt finds other content with the given checksum and size.
+const findContentStmt = "select id from contents where sha256 = ? and size = ? and id <> ? order by id limit 1;"
+
+const retainContentStmt = "update contents set refcount = refcount + 1 where id = ?;"
+
+const releaseContentStmt = "update contents set refcount = refcount - 1 where id = ? returning refcount;"
 
-const getStmt = "select path, blob, created_at, updated_at from blobs where path = ?;"
+const deleteContentStmt = "delete from contents where id = ?;"
 
-const deleteStmt = "delete from blobs where path = ?;"
+const insertChunkStmt = "insert into content_chunks (content_id, start, data) values (?, ?, ?);"
 
+// getChunkStmt gets the chunk containing the given offset.
+const getChunkStmt = `
+	select start, data from content_chunks
+	where content_id = ? and start <= ?
+	order by start desc
+	limit 1;`
+
+const listChunksStmt = "select data from content_chunks where content_id = ? order by start;"
+
+const deleteChunksStmt = "delete from content_chunks where content_id = ?;"
+
+type statements struct {
+	upsertBlob     *sql.Stmt
+	getBlob        *sql.Stmt
+	listBlobs      *sql.Stmt
+	getBlobContent *sql.Stmt
+	deleteBlob     *sql.Stmt
+	// deleteBlobUnchangedSince is used by the garbage collector.
+	deleteBlobUnchangedSince *sql.Stmt
+	insertContent            *sql.Stmt
+	updateContent            *sql.Stmt
+	findContent              *sql.Stmt
+	retainContent            *sql.Stmt
+	releaseContent           *sql.Stmt
+	deleteContent            *sql.Stmt
+	insertChunk              *sql.Stmt
+	getChunk                 *sql.Stmt
+	listChunks               *sql.Stmt
+	deleteChunks             *sql.Stmt
+}
+
+// Client stores blobs in a SQLite database.
+//
+// The database is opened in WAL mode, so reads do not block writes or each other. Writes go through DB, which
+// has a single connection as SQLite only allows one writer at a time, and reads through a separate pool of
+// read-only connections.
 type Client struct {
+	// DB is the connection pool used for writes.
 	DB         *sql.DB
-	statements struct {
-		add    *sql.Stmt
-		get    *sql.Stmt
-		delete *sql.Stmt
-	}
+	readDB     *sql.DB
+	options    Options
+	statements statements
 }
 
-type BlobResult struct {
-	Path      string
-	BLOB      io.Reader
+// Options configures a Client.
+type Options struct {
+	// Deduplicate stores identical content once, however many paths it is stored at.
+	//
+	// The content of every blob is stored in a content row, which paths reference and which is freed when the
+	// last reference goes. Without deduplication each Put stores new content; with it, content with the same
+	// SHA-256 checksum and size as existing content is discarded and the existing content referenced instead.
+	Deduplicate bool
+	// BusyTimeout is how long to wait for the database to be unlocked by another process, such as cmd/blight,
+	// before failing. Defaults to 5 seconds.
+	BusyTimeout time.Duration
+	// MaxReaders is the maximum number of concurrent read connections. Each open BlobReader holds a read
+	// connection until it is closed, so this limits how many blobs can be streamed at once, such as to slow
+	// clients, and is not bound by the number of CPUs. Defaults to 64.
+	MaxReaders int
+}
+
+const (
+	defaultBusyTimeout = 5 * time.Second
+	defaultMaxReaders  = 64
+)
+
+// BlobInfo describes a stored blob.
+type BlobInfo struct {
+	Path string
+	Size int64
+	// ContentType is the MIME type given when the blob was stored, or detected from its content otherwise.
+	ContentType string
+	// SHA256 is the hex encoded SHA-256 checksum of the blob.
+	SHA256    string
+	Metadata  map[string]string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// contentID is the ID of the content row the blob references.
+	contentID int64
+}
+
+type BlobResult struct {
+	BlobInfo
+	BLOB io.Reader
+}
+
+// PutOptions are optional attributes stored with a blob.
+type PutOptions struct {
+	// ContentType is the MIME type of the blob. It is detected from the content of the blob if empty.
+	ContentType string
+	Metadata    map[string]string
 }
 
 func New(path string) (*Client, error) {
-	db, err := sql.Open("sqlite3", path)
+	return NewWithOptions(path, Options{})
+}
+
+func NewWithOptions(path string, opts Options) (*Client, error) {
+	if opts.BusyTimeout <= 0 {
+		opts.BusyTimeout = defaultBusyTimeout
+	}
+	if opts.MaxReaders <= 0 {
+		opts.MaxReaders = defaultMaxReaders
+	}
+
+	// Write transactions take the lock when they begin, as SQLite cannot wait for the lock to be released
+	// when a read transaction is upgraded to a write transaction.
+	db, err := sql.Open("sqlite3", dsn(path, url.Values{
+		"_journal_mode": {"WAL"},
+		"_synchronous":  {"NORMAL"},
+		"_busy_timeout": {strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10)},
+		"_txlock":       {"immediate"},
+	}))
 	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(1)
 
 	if err := db.Ping(); err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	c := &Client{DB: db}
+	c := &Client{DB: db, options: opts}
 	if err := c.initializeDatabase(); err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	if err := c.initializePreparedStatements(); err != nil {
+	// The read-only connections are opened once the database has been created and migrated.
+	c.readDB, err = sql.Open("sqlite3", dsn(path, url.Values{
+		"mode":          {"ro"},
+		"_busy_timeout": {strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10)},
+	}))
+	if err != nil {
 		db.Close()
+		return nil, err
+	}
+	c.readDB.SetMaxOpenConns(opts.MaxReaders)
+	c.readDB.SetMaxIdleConns(opts.MaxReaders)
+
+	if err := c.initializePreparedStatements(); err != nil {
+		c.Close()
 		return nil, fmt.Errorf("error preparing statements: %w", err)
 	}
 
 	return c, nil
 }
 
+// dsn returns the URI filename of the database at path with the given parameters.
+func dsn(path string, params url.Values) string {
+	return "file:" + strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path) + "?" + params.Encode()
+}
+
 func (c *Client) Close() error {
-	if c.statements.add != nil {
-		c.statements.add.Close()
-	}
-	if c.statements.get != nil {
-		c.statements.add.Close()
+	for _, stmt := range []*sql.Stmt{
+		c.statements.upsertBlob,
+		c.statements.getBlob,
+		c.statements.listBlobs,
+		c.statements.getBlobContent,
+		c.statements.deleteBlob,
+		c.statements.deleteBlobUnchangedSince,
+		c.statements.insertContent,
+		c.statements.updateContent,
+		c.statements.findContent,
+		c.statements.retainContent,
+		c.statements.releaseContent,
+		c.statements.deleteContent,
+		c.statements.insertChunk,
+		c.statements.getChunk,
+		c.statements.listChunks,
+		c.statements.deleteChunks,
+	} {
+		if stmt != nil {
+			stmt.Close()
+		}
 	}
-	if c.statements.delete != nil {
-		c.statements.add.Close()
+	if c.readDB != nil {
+		c.readDB.Close()
 	}
 	return c.DB.Close()
 }
 
+// Add stores the blob at the given path, replacing any existing blob.
+// Use Put to pass a context.
 func (c *Client) Add(path string, r io.Reader) error {
-	blob, err := io.ReadAll(r)
+	return c.Put(context.Background(), path, r, -1)
+}
+
+// Put streams the blob from r to the given path, replacing any existing blob.
+// If size is not negative, ErrSizeMismatch is returned and nothing is stored unless exactly size bytes are read.
+func (c *Client) Put(ctx context.Context, path string, r io.Reader, size int64) error {
+	return c.PutWithOptions(ctx, path, r, size, PutOptions{})
+}
+
+// PutWithOptions is Put, storing the content type and metadata given by opts with the blob.
+func (c *Client) PutWithOptions(ctx context.Context, path string, r io.Reader, size int64, opts PutOptions) error {
+	metadata, err := json.Marshal(opts.Metadata)
 	if err != nil {
-		return fmt.Errorf("failed to read blob data: %w", err)
+		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	if _, err := c.statements.add.Exec(path, blob, blob); err != nil {
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The checksum is not known until the blob has been read, so its chunks are always written to new content,
+	// which is discarded afterwards if deduplication finds identical content.
+	result, err := tx.StmtContext(ctx, c.statements.insertContent).ExecContext(ctx)
+	if err != nil {
 		return fmt.Errorf("failed to add blob: %w", err)
 	}
-	return nil
+	contentID, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to add blob: %w", err)
+	}
+
+	insertChunk := tx.StmtContext(ctx, c.statements.insertChunk)
+	hash := sha256.New()
+	contentType := opts.ContentType
+	buf := make([]byte, chunkSize)
+	var written int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if contentType == "" {
+				contentType = http.DetectContentType(buf[:n])
+			}
+			hash.Write(buf[:n])
+			if _, err := insertChunk.ExecContext(ctx, contentID, written, buf[:n]); err != nil {
+				return fmt.Errorf("failed to add blob: %w", err)
+			}
+			written += int64(n)
+		}
+		if size >= 0 && written > size {
+			break
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read blob data: %w", err)
+		}
+	}
+
+	if size >= 0 && written != size {
+		return fmt.Errorf("%w: expected %d bytes", ErrSizeMismatch, size)
+	}
+
+	if contentType == "" {
+		contentType = http.DetectContentType(nil)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if _, err := tx.StmtContext(ctx, c.statements.updateContent).ExecContext(ctx, checksum, written, contentID); err != nil {
+		return fmt.Errorf("failed to add blob: %w", err)
+	}
+	if c.options.Deduplicate {
+		var existingID int64
+		err := tx.StmtContext(ctx, c.statements.findContent).QueryRowContext(ctx, checksum, written, contentID).Scan(&existingID)
+		switch {
+		case err == nil:
+			if err := deleteContent(ctx, tx, c.statements, contentID); err != nil {
+				return fmt.Errorf("failed to add blob: %w", err)
+			}
+			contentID = existingID
+		case !errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("failed to find identical content: %w", err)
+		}
+	}
+
+	var previousID sql.NullInt64
+	err = tx.StmtContext(ctx, c.statements.getBlobContent).QueryRowContext(ctx, path).Scan(&previousID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get existing blob: %w", err)
+	}
+
+	_, err = tx.StmtContext(ctx, c.statements.upsertBlob).ExecContext(ctx, path, written, contentType, checksum, string(metadata), contentID)
+	if err != nil {
+		return fmt.Errorf("failed to add blob: %w", err)
+	}
+	if _, err := tx.StmtContext(ctx, c.statements.retainContent).ExecContext(ctx, contentID); err != nil {
+		return fmt.Errorf("failed to add blob: %w", err)
+	}
+	if previousID.Valid {
+		if err := releaseContent(ctx, tx, c.statements, previousID.Int64); err != nil {
+			return fmt.Errorf("failed to replace existing blob: %w", err)
+		}
+	}
+	return tx.Commit()
 }
 
-func (c *Client) Get(path string) (*BlobResult, error) {
-	var res BlobResult
-	var blob []byte
-	if err := c.statements.get.QueryRow(path).Scan(&res.Path, &blob, &res.CreatedAt, &res.UpdatedAt); err != nil {
+// releaseContent removes a reference to the content, deleting it when the last reference goes.
+func releaseContent(ctx context.Context, tx *sql.Tx, stmts statements, id int64) error {
+	var refcount int64
+	err := tx.StmtContext(ctx, stmts.releaseContent).QueryRowContext(ctx, id).Scan(&refcount)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrBlobNotFound
+			return nil
 		}
+		return err
+	}
+	if refcount > 0 {
+		return nil
+	}
+	return deleteContent(ctx, tx, stmts, id)
+}
+
+func deleteContent(ctx context.Context, tx *sql.Tx, stmts statements, id int64) error {
+	if _, err := tx.StmtContext(ctx, stmts.deleteChunks).ExecContext(ctx, id); err != nil {
+		return err
+	}
+	_, err := tx.StmtContext(ctx, stmts.deleteContent).ExecContext(ctx, id)
+	return err
+}
+
+// Get reads the whole blob at the given path into memory.
+// Use Open to stream large blobs instead.
+func (c *Client) Get(path string) (*BlobResult, error) {
+	return c.GetContext(context.Background(), path)
+}
+
+// GetContext is Get with a context.
+func (c *Client) GetContext(ctx context.Context, path string) (*BlobResult, error) {
+	tx, err := c.readDB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	info, err := scanBlobInfo(tx.StmtContext(ctx, c.statements.getBlob).QueryRowContext(ctx, path))
+	if err != nil {
+		return nil, err
+	}
+	res := BlobResult{BlobInfo: *info}
+
+	rows, err := tx.StmtContext(ctx, c.statements.listChunks).QueryContext(ctx, info.contentID)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get blob: %w", err)
 	}
+	defer rows.Close()
 
-	res.BLOB = bytes.NewReader(blob)
+	blob := bytes.NewBuffer(make([]byte, 0, res.Size))
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to get blob: %w", err)
+		}
+		blob.Write(data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get blob: %w", err)
+	}
+
+	res.BLOB = blob
 	return &res, nil
 }
 
+// Open opens the blob at the given path for reading.
+// The blob is read a chunk at a time from a consistent snapshot, so it is unaffected by concurrent writes
+// to the same path. The BlobReader holds one of the MaxReaders read connections and must be closed to release
+// the snapshot and the connection.
+func (c *Client) Open(ctx context.Context, path string) (*BlobReader, error) {
+	tx, err := c.readDB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	info, err := scanBlobInfo(tx.StmtContext(ctx, c.statements.getBlob).QueryRowContext(ctx, path))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return &BlobReader{
+		BlobInfo: *info,
+		ctx:      ctx,
+		tx:       tx,
+		getChunk: tx.StmtContext(ctx, c.statements.getChunk),
+	}, nil
+}
+
+// Stat returns the attributes of the blob at the given path without reading it.
+func (c *Client) Stat(ctx context.Context, path string) (*BlobInfo, error) {
+	return scanBlobInfo(c.statements.getBlob.QueryRowContext(ctx, path))
+}
+
+// List returns the attributes of the blobs with paths starting with prefix, ordered by path.
+func (c *Client) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	rows, err := c.statements.listBlobs.QueryContext(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	defer rows.Close()
+
+	var blobs []BlobInfo
+	for rows.Next() {
+		info, err := scanBlobInfo(rows)
+		if err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, *info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	return blobs, nil
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBlobInfo(row scanner) (*BlobInfo, error) {
+	var info BlobInfo
+	var metadata string
+	err := row.Scan(&info.Path, &info.Size, &info.ContentType, &info.SHA256, &metadata, &info.CreatedAt, &info.UpdatedAt, &info.contentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, fmt.Errorf("failed to get blob: %w", err)
+	}
+	if err := json.Unmarshal([]byte(metadata), &info.Metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+	return &info, nil
+}
+
+// Delete deletes the blob at the given path, freeing its content unless it is stored at other paths.
 func (c *Client) Delete(path string) error {
-	result, err := c.statements.delete.Exec(path)
+	return c.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext is Delete with a context.
+func (c *Client) DeleteContext(ctx context.Context, path string) error {
+	tx, err := c.DB.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var contentID int64
+	if err := tx.StmtContext(ctx, c.statements.deleteBlob).QueryRowContext(ctx, path).Scan(&contentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrBlobNotFound
+		}
 		return fmt.Errorf("failed to delete blob: %w", err)
 	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
+
+	if err := releaseContent(ctx, tx, c.statements, contentID); err != nil {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return tx.Commit()
+}
+
+// BlobReader streams a blob opened with Client.Open.
+type BlobReader struct {
+	BlobInfo
+
+	ctx      context.Context
+	tx       *sql.Tx
+	getChunk *sql.Stmt
+	offset   int64
+
+	// chunk is the most recently read chunk, which starts at chunkStart.
+	chunk      []byte
+	chunkStart int64
+}
+
+var _ io.ReadSeekCloser = (*BlobReader)(nil)
+
+func (br *BlobReader) Read(p []byte) (int, error) {
+	if br.offset >= br.Size {
+		return 0, io.EOF
 	}
-	if rowsAffected == 0 {
-		return ErrBlobNotFound
+
+	if br.offset < br.chunkStart || br.offset >= br.chunkStart+int64(len(br.chunk)) {
+		if err := br.getChunk.QueryRowContext(br.ctx, br.contentID, br.offset).Scan(&br.chunkStart, &br.chunk); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, fmt.Errorf("failed to read blob: %w", err)
+		}
+		if br.offset >= br.chunkStart+int64(len(br.chunk)) {
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+
+	n := copy(p, br.chunk[br.offset-br.chunkStart:])
+	br.offset += int64(n)
+	return n, nil
+}
+
+func (br *BlobReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += br.offset
+	case io.SeekEnd:
+		offset += br.Size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
 	}
-	return nil
+	br.offset = offset
+	return offset, nil
+}
+
+// Close releases the snapshot the blob is read from.
+func (br *BlobReader) Close() error {
+	return br.tx.Rollback()
 }
@@ -0,0 +1,112 @@
+package blight
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	benchBlobs    = 200
+	benchBlobSize = 64 << 10
+)
+
+// newBenchClient creates a client on a temporary database holding benchBlobs random blobs, returning their paths.
+func newBenchClient(b *testing.B) (*Client, []string) {
+	b.Helper()
+	client, err := New(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { client.Close() })
+
+	ctx := context.Background()
+	data := make([]byte, benchBlobSize)
+	paths := make([]string, benchBlobs)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("bench/%d", i)
+		_, _ = rand.Read(data)
+		if err := client.Put(ctx, paths[i], bytes.NewReader(data), int64(len(data))); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return client, paths
+}
+
+// benchmarkRead reads random blobs in full from parallel readers, optionally while a writer replaces random blobs.
+func benchmarkRead(b *testing.B, writer bool) {
+	client, paths := newBenchClient(b)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		writes   int
+		writeErr error
+	)
+	if writer {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := make([]byte, benchBlobSize)
+			for ctx.Err() == nil {
+				_, _ = rand.Read(data)
+				err := client.Put(ctx, paths[mathrand.IntN(len(paths))], bytes.NewReader(data), int64(len(data)))
+				if err != nil {
+					if ctx.Err() == nil {
+						writeErr = err
+					}
+					return
+				}
+				writes++
+			}
+		}()
+	}
+
+	b.SetBytes(benchBlobSize)
+	b.ResetTimer()
+	start := time.Now()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			r, err := client.Open(ctx, paths[mathrand.IntN(len(paths))])
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			_, err = io.Copy(io.Discard, r)
+			r.Close()
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.StopTimer()
+	elapsed := time.Since(start)
+
+	cancel()
+	wg.Wait()
+	if writeErr != nil {
+		b.Fatal(writeErr)
+	}
+	if writer {
+		b.ReportMetric(float64(writes)/elapsed.Seconds(), "writes/s")
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	benchmarkRead(b, false)
+}
+
+// BenchmarkReadUnderConcurrentWriter measures how much reads are slowed down by a writer, which WAL mode
+// and the separate reader pool should keep to a minimum. Compare it with BenchmarkRead.
+func BenchmarkReadUnderConcurrentWriter(b *testing.B) {
+	benchmarkRead(b, true)
+}
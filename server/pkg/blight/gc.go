@@ -0,0 +1,92 @@
+package blight
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sqliteTimestampFormat is the format of CURRENT_TIMESTAMP, which created_at and updated_at default to.
+const sqliteTimestampFormat = "2006-01-02 15:04:05"
+
+type GCOptions struct {
+	// Referenced reports whether the blob at the path is still in use; blobs which are not are removed.
+	Referenced func(path string) bool
+	// MinAge protects blobs updated more recently than this from removal, as they may have been stored
+	// before the record referencing them was created.
+	MinAge time.Duration
+	// DryRun reports the blobs which would be removed without removing them.
+	DryRun bool
+}
+
+type GCReport struct {
+	// Scanned is the number of blobs considered for removal.
+	Scanned int
+	// Removed are the blobs which were removed, or would have been removed in a dry run.
+	Removed []BlobInfo
+	// RemovedBytes is the total size of the removed blobs. Less space is freed when deduplicated content
+	// is still stored at other paths.
+	RemovedBytes int64
+}
+
+// CollectGarbage removes the blobs which are no longer referenced.
+// A blob updated during collection is never removed, even if it is not referenced.
+func (c *Client) CollectGarbage(ctx context.Context, opts GCOptions) (*GCReport, error) {
+	if opts.Referenced == nil {
+		return nil, errors.New("no Referenced function given")
+	}
+
+	blobs, err := c.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().UTC().Add(-opts.MinAge)
+	report := &GCReport{Scanned: len(blobs)}
+	for _, b := range blobs {
+		if b.UpdatedAt.After(cutoff) || opts.Referenced(b.Path) {
+			continue
+		}
+
+		if !opts.DryRun {
+			removed, err := c.deleteUnchangedSince(ctx, b.Path, cutoff)
+			if err != nil {
+				return report, err
+			}
+			if !removed {
+				continue
+			}
+		}
+		report.Removed = append(report.Removed, b)
+		report.RemovedBytes += b.Size
+	}
+	return report, nil
+}
+
+// deleteUnchangedSince deletes the blob if it has not been updated since the cutoff,
+// reporting whether it was deleted.
+func (c *Client) deleteUnchangedSince(ctx context.Context, path string, cutoff time.Time) (bool, error) {
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var contentID int64
+	err = tx.StmtContext(ctx, c.statements.deleteBlobUnchangedSince).
+		QueryRowContext(ctx, path, cutoff.Format(sqliteTimestampFormat)).
+		Scan(&contentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to delete blob: %w", err)
+	}
+
+	if err := releaseContent(ctx, tx, c.statements, contentID); err != nil {
+		return false, fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return true, tx.Commit()
+}
@@ -1,14 +1,29 @@
 package blight
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"net/http"
 )
 
 func (c *Client) initializeDatabase() error {
 	if err := c.createBlobsTable(); err != nil {
 		return fmt.Errorf("%w: %v", ErrCreatingDatabase, err)
 	}
+	if err := c.createContentTables(); err != nil {
+		return fmt.Errorf("%w: %v", ErrCreatingDatabase, err)
+	}
+	if err := c.migrateInlineBlobs(); err != nil {
+		return fmt.Errorf("%w: %v", ErrCreatingDatabase, err)
+	}
+	if err := c.migrateBlobAttributes(); err != nil {
+		return fmt.Errorf("%w: %v", ErrCreatingDatabase, err)
+	}
+	if err := c.migrateChunkedBlobs(); err != nil {
+		return fmt.Errorf("%w: %v", ErrCreatingDatabase, err)
+	}
 	if err := c.createUpdateTrigger(); err != nil {
 		return fmt.Errorf("%w: %v", ErrCreatingDatabase, err)
 	}
@@ -19,7 +34,11 @@ func (c *Client) createBlobsTable() error {
 	stmt := `
 		CREATE TABLE IF NOT EXISTS blobs (
 			path TEXT PRIMARY KEY,
-			blob BLOB NOT NULL,
+			size INTEGER NOT NULL DEFAULT 0,
+			content_type TEXT NOT NULL DEFAULT 'application/octet-stream',
+			sha256 TEXT NOT NULL DEFAULT '',
+			metadata TEXT NOT NULL DEFAULT '{}',
+			content_id INTEGER,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		);`
@@ -31,6 +50,218 @@ func (c *Client) createBlobsTable() error {
 	return nil
 }
 
+// createContentTables creates the tables the content of blobs is stored in.
+// Each content row is referenced by refcount blobs, and its data is stored in chunks of up to chunkSize bytes.
+func (c *Client) createContentTables() error {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS contents (
+			id INTEGER PRIMARY KEY,
+			sha256 TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			refcount INTEGER NOT NULL DEFAULT 0
+		);`,
+		"CREATE INDEX IF NOT EXISTS idx_contents_sha256 ON contents (sha256);",
+		`CREATE TABLE IF NOT EXISTS content_chunks (
+			content_id INTEGER NOT NULL,
+			start INTEGER NOT NULL,
+			data BLOB NOT NULL,
+			PRIMARY KEY (content_id, start)
+		);`,
+	} {
+		if _, err := c.DB.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create content tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// createLegacyChunksTable creates the blob_chunks table blobs were stored in, keyed by path, before content
+// was stored separately. It is only needed to migrate inline blobs.
+func (c *Client) createLegacyChunksTable() error {
+	stmt := `
+		CREATE TABLE IF NOT EXISTS blob_chunks (
+			path TEXT NOT NULL,
+			start INTEGER NOT NULL,
+			data BLOB NOT NULL,
+			PRIMARY KEY (path, start)
+		);`
+
+	_, err := c.DB.Exec(stmt)
+	if err != nil {
+		return fmt.Errorf("failed to create blob_chunks table: %w", err)
+	}
+	return nil
+}
+
+// migrateInlineBlobs moves blobs stored inline in the blobs table, by earlier versions, into blob_chunks.
+// Each blob is moved as a single chunk. migrateChunkedBlobs moves them on into content rows.
+func (c *Client) migrateInlineBlobs() error {
+	inline, err := c.columnExists("blobs", "blob")
+	if err != nil || !inline {
+		return err
+	}
+	if err := c.createLegacyChunksTable(); err != nil {
+		return err
+	}
+
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The trigger is recreated afterwards; it is dropped so migrating does not change updated_at.
+	for _, stmt := range []string{
+		"DROP TRIGGER IF EXISTS tr_blobs_set_updated_at;",
+		"INSERT INTO blob_chunks (path, start, data) SELECT path, 0, blob FROM blobs WHERE length(blob) > 0;",
+		"ALTER TABLE blobs ADD COLUMN size INTEGER NOT NULL DEFAULT 0;",
+		"UPDATE blobs SET size = length(blob);",
+		"ALTER TABLE blobs DROP COLUMN blob;",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to migrate inline blobs: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// migrateChunkedBlobs moves the chunks of blobs stored in blob_chunks, by earlier versions, into content rows.
+// Each blob gets its own content, using the rowid of the blob as the content ID; identical content is not merged.
+func (c *Client) migrateChunkedBlobs() error {
+	chunked, err := c.tableExists("blob_chunks")
+	if err != nil || !chunked {
+		return err
+	}
+	hasContent, err := c.columnExists("blobs", "content_id")
+	if err != nil {
+		return err
+	}
+
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{"DROP TRIGGER IF EXISTS tr_blobs_set_updated_at;"}
+	if !hasContent {
+		stmts = append(stmts, "ALTER TABLE blobs ADD COLUMN content_id INTEGER;")
+	}
+	stmts = append(stmts,
+		"INSERT INTO contents (id, sha256, size, refcount) SELECT rowid, sha256, size, 1 FROM blobs;",
+		`INSERT INTO content_chunks (content_id, start, data)
+			SELECT b.rowid, c.start, c.data FROM blob_chunks c JOIN blobs b ON b.path = c.path;`,
+		"UPDATE blobs SET content_id = rowid;",
+		"DROP TABLE blob_chunks;",
+	)
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to migrate chunked blobs: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// migrateBlobAttributes adds the content type, checksum and metadata columns to blobs tables created by
+// earlier versions, detecting the content type and calculating the checksum of the existing blobs.
+func (c *Client) migrateBlobAttributes() error {
+	exists, err := c.columnExists("blobs", "sha256")
+	if err != nil || exists {
+		return err
+	}
+
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		"DROP TRIGGER IF EXISTS tr_blobs_set_updated_at;",
+		"ALTER TABLE blobs ADD COLUMN content_type TEXT NOT NULL DEFAULT 'application/octet-stream';",
+		"ALTER TABLE blobs ADD COLUMN sha256 TEXT NOT NULL DEFAULT '';",
+		"ALTER TABLE blobs ADD COLUMN metadata TEXT NOT NULL DEFAULT '{}';",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to migrate blob attributes: %w", err)
+		}
+	}
+
+	var paths []string
+	rows, err := tx.Query("SELECT path FROM blobs;")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return err
+		}
+		paths = append(paths, path)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		contentType, checksum, err := calculateBlobAttributes(tx, path)
+		if err != nil {
+			return fmt.Errorf("failed to calculate attributes of %s: %w", path, err)
+		}
+		if _, err := tx.Exec("UPDATE blobs SET content_type = ?, sha256 = ? WHERE path = ?;", contentType, checksum, path); err != nil {
+			return fmt.Errorf("failed to migrate blob attributes: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// calculateBlobAttributes reads the blob from blob_chunks, as blobs created by the versions without
+// the attribute columns are always stored there.
+func calculateBlobAttributes(tx *sql.Tx, path string) (contentType, checksum string, err error) {
+	rows, err := tx.Query("SELECT data FROM blob_chunks WHERE path = ? ORDER BY start;", path)
+	if err != nil {
+		return "", "", err
+	}
+	defer rows.Close()
+
+	hash := sha256.New()
+	var head []byte
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return "", "", err
+		}
+		if head == nil {
+			head = data
+		}
+		hash.Write(data)
+	}
+	if err := rows.Err(); err != nil {
+		return "", "", err
+	}
+	return http.DetectContentType(head), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (c *Client) columnExists(table, column string) (bool, error) {
+	var exists bool
+	err := c.DB.QueryRow("SELECT count(*) > 0 FROM pragma_table_info(?) WHERE name = ?;", table, column).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+	return exists, nil
+}
+
+func (c *Client) tableExists(table string) (bool, error) {
+	var exists bool
+	err := c.DB.QueryRow("SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?;", table).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	return exists, nil
+}
+
 func (c *Client) createUpdateTrigger() error {
 	stmt := `
 		CREATE TRIGGER IF NOT EXISTS tr_blobs_set_updated_at
@@ -50,27 +281,35 @@ func (c *Client) createUpdateTrigger() error {
 }
 
 func (c *Client) initializePreparedStatements() error {
-	add, err := c.DB.Prepare(addStmt)
-	if err != nil {
-		return err
-	}
-	get, err := c.DB.Prepare(getStmt)
-	if err != nil {
-		return err
-	}
-	del, err := c.DB.Prepare(deleteStmt)
-	if err != nil {
-		return err
-	}
-
-	c.statements = struct {
-		add    *sql.Stmt
-		get    *sql.Stmt
-		delete *sql.Stmt
-	}{
-		add:    add,
-		get:    get,
-		delete: del,
+	for db, queries := range map[*sql.DB]map[**sql.Stmt]string{
+		c.DB: {
+			&c.statements.upsertBlob:               upsertBlobStmt,
+			&c.statements.getBlobContent:           getBlobContentStmt,
+			&c.statements.deleteBlob:               deleteBlobStmt,
+			&c.statements.deleteBlobUnchangedSince: deleteBlobUnchangedSinceStmt,
+			&c.statements.insertContent:            insertContentStmt,
+			&c.statements.updateContent:            updateContentStmt,
+			&c.statements.findContent:              findContentStmt,
+			&c.statements.retainContent:            retainContentStmt,
+			&c.statements.releaseContent:           releaseContentStmt,
+			&c.statements.deleteContent:            deleteContentStmt,
+			&c.statements.insertChunk:              insertChunkStmt,
+			&c.statements.deleteChunks:             deleteChunksStmt,
+		},
+		c.readDB: {
+			&c.statements.getBlob:    getBlobStmt,
+			&c.statements.listBlobs:  listBlobsStmt,
+			&c.statements.getChunk:   getChunkStmt,
+			&c.statements.listChunks: listChunksStmt,
+		},
+	} {
+		for dst, query := range queries {
+			stmt, err := db.Prepare(query)
+			if err != nil {
+				return err
+			}
+			*dst = stmt
+		}
 	}
 	return nil
 }
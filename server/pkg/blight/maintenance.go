@@ -0,0 +1,242 @@
+package blight
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Backup copies the database to a new file at destPath with the SQLite online backup API.
+// The database is copied in a single step from a read-only connection, so the copy is a consistent snapshot
+// and writes continue while it is taken; copying in several steps would restart whenever a blob is written.
+// Backup fails if destPath already exists rather than overwriting it.
+func (c *Client) Backup(ctx context.Context, destPath string) error {
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("backup destination %s already exists", destPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	dest, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open backup destination: %w", err)
+	}
+	defer destConn.Close()
+
+	srcConn, err := c.readDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriverConn any) error {
+		return srcConn.Raw(func(srcDriverConn any) error {
+			backup, err := destDriverConn.(*sqlite3.SQLiteConn).Backup("main", srcDriverConn.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+
+			done, err := backup.Step(-1)
+			if err != nil {
+				backup.Close()
+				return fmt.Errorf("failed to back up database: %w", err)
+			}
+			if !done {
+				backup.Close()
+				return errors.New("failed to back up database: database is locked")
+			}
+			return backup.Finish()
+		})
+	})
+}
+
+// Vacuum rebuilds the database file, returning the space freed by deleted blobs to the file system.
+// It needs free disk space of up to twice the size of the database and blocks writes until it completes.
+func (c *Client) Vacuum(ctx context.Context) error {
+	if _, err := c.DB.ExecContext(ctx, "VACUUM;"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
+
+type VerifyReport struct {
+	// Checked is the number of blobs verified.
+	Checked int
+	// Problems are the inconsistencies found. The database is intact if there are none.
+	Problems []VerifyProblem
+}
+
+type VerifyProblem struct {
+	// Path is the path of the affected blob, or empty for problems with the database as a whole.
+	Path        string
+	Description string
+}
+
+// Verify checks the integrity of the database file, and recomputes the checksum and size of the content of
+// every blob to check they match the stored values. Content shared by several blobs is only read once.
+// The reference counts of the content are also checked, as content is freed early if they are too low.
+func (c *Client) Verify(ctx context.Context) (*VerifyReport, error) {
+	tx, err := c.readDB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	report := &VerifyReport{}
+	if err := verifyIntegrity(ctx, tx, report); err != nil {
+		return nil, err
+	}
+	if err := verifyRefcounts(ctx, tx, report); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.StmtContext(ctx, c.statements.listBlobs).QueryContext(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	var blobs []BlobInfo
+	for rows.Next() {
+		info, err := scanBlobInfo(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		blobs = append(blobs, *info)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	type content struct {
+		checksum string
+		size     int64
+	}
+	contents := make(map[int64]content)
+	listChunks := tx.StmtContext(ctx, c.statements.listChunks)
+	for _, b := range blobs {
+		report.Checked++
+		actual, ok := contents[b.contentID]
+		if !ok {
+			checksum, size, err := hashContent(ctx, listChunks, b.contentID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", b.Path, err)
+			}
+			actual = content{checksum: checksum, size: size}
+			contents[b.contentID] = actual
+		}
+
+		if actual.size != b.Size {
+			report.Problems = append(report.Problems, VerifyProblem{
+				Path:        b.Path,
+				Description: fmt.Sprintf("stored size is %d bytes but the content is %d bytes", b.Size, actual.size),
+			})
+		}
+		if actual.checksum != b.SHA256 {
+			report.Problems = append(report.Problems, VerifyProblem{
+				Path:        b.Path,
+				Description: fmt.Sprintf("stored checksum is %s but the content hashes to %s", b.SHA256, actual.checksum),
+			})
+		}
+	}
+	return report, nil
+}
+
+// verifyIntegrity runs the SQLite integrity check, which reports corruption of the database file.
+func verifyIntegrity(ctx context.Context, tx *sql.Tx, report *VerifyReport) error {
+	rows, err := tx.QueryContext(ctx, "PRAGMA integrity_check;")
+	if err != nil {
+		return fmt.Errorf("failed to check integrity: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return fmt.Errorf("failed to check integrity: %w", err)
+		}
+		if result != "ok" {
+			report.Problems = append(report.Problems, VerifyProblem{Description: result})
+		}
+	}
+	return rows.Err()
+}
+
+// verifyRefcounts reports content whose reference count differs from the number of blobs referencing it,
+// and blobs referencing content which does not exist.
+func verifyRefcounts(ctx context.Context, tx *sql.Tx, report *VerifyReport) error {
+	stmt := `
+		select c.id, c.refcount, count(b.path)
+		from contents c
+		left join blobs b on b.content_id = c.id
+		group by c.id
+		having c.refcount <> count(b.path);`
+
+	rows, err := tx.QueryContext(ctx, stmt)
+	if err != nil {
+		return fmt.Errorf("failed to check reference counts: %w", err)
+	}
+	for rows.Next() {
+		var id, refcount, references int64
+		if err := rows.Scan(&id, &refcount, &references); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to check reference counts: %w", err)
+		}
+		report.Problems = append(report.Problems, VerifyProblem{
+			Description: fmt.Sprintf("content %d has a reference count of %d but %d references", id, refcount, references),
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to check reference counts: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, "select path from blobs where content_id not in (select id from contents);")
+	if err != nil {
+		return fmt.Errorf("failed to check content references: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return fmt.Errorf("failed to check content references: %w", err)
+		}
+		report.Problems = append(report.Problems, VerifyProblem{Path: path, Description: "content is missing"})
+	}
+	return rows.Err()
+}
+
+// hashContent recomputes the checksum and size of the content from its chunks.
+func hashContent(ctx context.Context, listChunks *sql.Stmt, contentID int64) (checksum string, size int64, err error) {
+	rows, err := listChunks.QueryContext(ctx, contentID)
+	if err != nil {
+		return "", 0, err
+	}
+	defer rows.Close()
+
+	hash := sha256.New()
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return "", 0, err
+		}
+		hash.Write(data)
+		size += int64(len(data))
+	}
+	if err := rows.Err(); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
@@ -0,0 +1,85 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"paws/pkg/blight"
+)
+
+// BlightStore stores blobs in a blight SQLite database.
+type BlightStore struct {
+	client *blight.Client
+}
+
+var _ BlobStore = (*BlightStore)(nil)
+
+func NewBlightStore(client *blight.Client) *BlightStore {
+	return &BlightStore{client: client}
+}
+
+func (s *BlightStore) Put(ctx context.Context, path string, r io.Reader, size int64, opts PutOptions) error {
+	if err := validatePath(path); err != nil {
+		return err
+	}
+	err := s.client.PutWithOptions(ctx, path, r, size, blight.PutOptions{
+		ContentType: opts.ContentType,
+		Metadata:    opts.Metadata,
+	})
+	return mapBlightError(err)
+}
+
+func (s *BlightStore) Get(ctx context.Context, path string) (*Blob, error) {
+	br, err := s.client.Open(ctx, path)
+	if err != nil {
+		return nil, mapBlightError(err)
+	}
+	return &Blob{Info: newInfoFromBlight(br.BlobInfo), ReadSeekCloser: br}, nil
+}
+
+func (s *BlightStore) Delete(ctx context.Context, path string) error {
+	return mapBlightError(s.client.DeleteContext(ctx, path))
+}
+
+func (s *BlightStore) List(ctx context.Context, prefix string) ([]Info, error) {
+	blobs, err := s.client.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]Info, len(blobs))
+	for i, b := range blobs {
+		infos[i] = newInfoFromBlight(b)
+	}
+	return infos, nil
+}
+
+func (s *BlightStore) Stat(ctx context.Context, path string) (*Info, error) {
+	b, err := s.client.Stat(ctx, path)
+	if err != nil {
+		return nil, mapBlightError(err)
+	}
+	info := newInfoFromBlight(*b)
+	return &info, nil
+}
+
+func newInfoFromBlight(b blight.BlobInfo) Info {
+	return Info{
+		Path:        b.Path,
+		Size:        b.Size,
+		ContentType: b.ContentType,
+		SHA256:      b.SHA256,
+		Metadata:    b.Metadata,
+		UpdatedAt:   b.UpdatedAt,
+	}
+}
+
+func mapBlightError(err error) error {
+	switch {
+	case errors.Is(err, blight.ErrBlobNotFound):
+		return ErrNotFound
+	case errors.Is(err, blight.ErrSizeMismatch):
+		return ErrSizeMismatch
+	}
+	return err
+}
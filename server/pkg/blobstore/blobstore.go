@@ -0,0 +1,69 @@
+// Package blobstore provides a common interface over the backends blobs, such as avatars, can be stored in.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("blob not found")
+	ErrInvalidPath  = errors.New("invalid blob path")
+	ErrSizeMismatch = errors.New("blob size does not match the expected size")
+)
+
+// BlobStore stores blobs by path. Paths are slash separated and relative, such as "avatars/1234".
+type BlobStore interface {
+	// Put streams the blob from r to the given path, replacing any existing blob.
+	// If size is not negative, ErrSizeMismatch is returned and nothing is stored unless exactly size bytes are read.
+	Put(ctx context.Context, path string, r io.Reader, size int64, opts PutOptions) error
+	// Get opens the blob at the given path for reading. The Blob must be closed.
+	Get(ctx context.Context, path string) (*Blob, error)
+	// Delete deletes the blob at the given path, returning ErrNotFound if it does not exist.
+	Delete(ctx context.Context, path string) error
+	// List returns the blobs with paths starting with prefix, ordered by path.
+	List(ctx context.Context, prefix string) ([]Info, error)
+	// Stat returns the attributes of the blob at the given path without reading it.
+	Stat(ctx context.Context, path string) (*Info, error)
+}
+
+// Info describes a stored blob.
+type Info struct {
+	Path        string
+	Size        int64
+	ContentType string
+	// SHA256 is the hex encoded SHA-256 checksum of the blob.
+	SHA256    string
+	Metadata  map[string]string
+	UpdatedAt time.Time
+}
+
+// Blob is a blob opened for reading with BlobStore.Get.
+type Blob struct {
+	Info
+	io.ReadSeekCloser
+}
+
+// PutOptions are optional attributes stored with a blob.
+type PutOptions struct {
+	// ContentType is the MIME type of the blob. It is detected from the content of the blob if empty.
+	ContentType string
+	Metadata    map[string]string
+}
+
+// validatePath checks the path is relative, clean and has no hidden segments,
+// which are reserved for use by the backends.
+func validatePath(path string) error {
+	if path == "" || strings.HasPrefix(path, "/") || strings.HasSuffix(path, "/") {
+		return ErrInvalidPath
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || strings.HasPrefix(segment, ".") || strings.Contains(segment, "\\") {
+			return ErrInvalidPath
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// metaDir is the directory, within the root, the attributes of each blob are stored in.
+const metaDir = ".meta"
+
+// FilesystemStore stores blobs as files within a root directory.
+// The attributes of each blob are stored in a JSON file of the same path within the .meta directory of the root.
+type FilesystemStore struct {
+	root string
+}
+
+var _ BlobStore = (*FilesystemStore)(nil)
+
+type fileAttributes struct {
+	ContentType string            `json:"content_type"`
+	SHA256      string            `json:"sha256"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+// NewFilesystemStore creates a FilesystemStore, creating the root directory if it does not exist.
+func NewFilesystemStore(root string) (*FilesystemStore, error) {
+	if err := os.MkdirAll(filepath.Join(root, metaDir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create root directory: %w", err)
+	}
+	return &FilesystemStore{root: root}, nil
+}
+
+func (s *FilesystemStore) Put(_ context.Context, path string, r io.Reader, size int64, opts PutOptions) error {
+	if err := validatePath(path); err != nil {
+		return err
+	}
+
+	name := s.blobPath(path)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// The blob is written to a temporary file which replaces any existing blob once it has been written in full.
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	head := &headWriter{}
+	written, err := io.Copy(io.MultiWriter(tmp, hash, head), r)
+	if err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if size >= 0 && written != size {
+		return ErrSizeMismatch
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	attrs := fileAttributes{
+		ContentType: opts.ContentType,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		Metadata:    opts.Metadata,
+	}
+	if attrs.ContentType == "" {
+		attrs.ContentType = http.DetectContentType(head.buf)
+	}
+	if err := s.writeAttributes(path, attrs); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	return nil
+}
+
+func (s *FilesystemStore) Get(ctx context.Context, path string) (*Blob, error) {
+	info, err := s.Stat(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.blobPath(path))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open blob: %w", err)
+	}
+	return &Blob{Info: *info, ReadSeekCloser: f}, nil
+}
+
+func (s *FilesystemStore) Delete(_ context.Context, path string) error {
+	if err := validatePath(path); err != nil {
+		return err
+	}
+	if err := os.Remove(s.blobPath(path)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	if err := os.Remove(s.attributesPath(path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob attributes: %w", err)
+	}
+	return nil
+}
+
+func (s *FilesystemStore) List(ctx context.Context, prefix string) ([]Info, error) {
+	var infos []Info
+	err := filepath.WalkDir(s.root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && name != s.root {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, name)
+		if err != nil {
+			return err
+		}
+		path := filepath.ToSlash(rel)
+		if !strings.HasPrefix(path, prefix) {
+			return nil
+		}
+
+		info, err := s.Stat(ctx, path)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, *info)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Path < infos[j].Path
+	})
+	return infos, nil
+}
+
+func (s *FilesystemStore) Stat(_ context.Context, path string) (*Info, error) {
+	if err := validatePath(path); err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(s.blobPath(path))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to stat blob: %w", err)
+	}
+
+	// Files copied into the root directory by hand have no attributes.
+	attrs := fileAttributes{ContentType: "application/octet-stream"}
+	data, err := os.ReadFile(s.attributesPath(path))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read blob attributes: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &attrs); err != nil {
+			return nil, fmt.Errorf("failed to read blob attributes: %w", err)
+		}
+	}
+
+	return &Info{
+		Path:        path,
+		Size:        fi.Size(),
+		ContentType: attrs.ContentType,
+		SHA256:      attrs.SHA256,
+		Metadata:    attrs.Metadata,
+		UpdatedAt:   fi.ModTime(),
+	}, nil
+}
+
+func (s *FilesystemStore) writeAttributes(path string, attrs fileAttributes) error {
+	name := s.attributesPath(path)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write blob attributes: %w", err)
+	}
+	return nil
+}
+
+func (s *FilesystemStore) blobPath(path string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path))
+}
+
+func (s *FilesystemStore) attributesPath(path string) string {
+	return filepath.Join(s.root, metaDir, filepath.FromSlash(path)+".json")
+}
+
+// headWriter keeps the first 512 bytes written to it, which is all http.DetectContentType considers.
+type headWriter struct {
+	buf []byte
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if remaining := 512 - len(w.buf); remaining > 0 {
+		w.buf = append(w.buf, p[:min(remaining, len(p))]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,357 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3MetaPrefix is the prefix of the headers user metadata is stored in.
+const s3MetaPrefix = "X-Amz-Meta-"
+
+// s3SHA256Key is the user metadata key the checksum of each blob is stored under.
+const s3SHA256Key = "Sha256"
+
+// S3Config configures an S3Store.
+type S3Config struct {
+	// Endpoint is the base URL of the S3-compatible service, such as https://s3.eu-west-2.amazonaws.com
+	// or http://localhost:9000 for MinIO.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// UsePathStyle addresses the bucket in the path rather than the host name, as required by MinIO.
+	UsePathStyle bool
+}
+
+// S3Store stores blobs as objects in a bucket of an S3-compatible service.
+// Requests are signed with AWS Signature Version 4.
+type S3Store struct {
+	config     S3Config
+	endpoint   *url.URL
+	httpClient *http.Client
+}
+
+var _ BlobStore = (*S3Store)(nil)
+
+func NewS3Store(config S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, errors.New("no S3 bucket configured")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	return &S3Store{
+		config:     config,
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Put spools the blob to a temporary file before uploading it, as the length and checksum of the payload
+// must be known before the request is signed.
+func (s *S3Store) Put(ctx context.Context, path string, r io.Reader, size int64, opts PutOptions) error {
+	if err := validatePath(path); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "blobstore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	head := &headWriter{}
+	written, err := io.Copy(io.MultiWriter(tmp, hash, head), r)
+	if err != nil {
+		return fmt.Errorf("failed to read blob: %w", err)
+	}
+	if size >= 0 && written != size {
+		return ErrSizeMismatch
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = http.DetectContentType(head.buf)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	header.Set(s3MetaPrefix+s3SHA256Key, checksum)
+	for k, v := range opts.Metadata {
+		if !validS3MetadataKey(k) {
+			return fmt.Errorf("invalid metadata key %q", k)
+		}
+		// Header values may only contain ASCII, so other values are encoded as in email headers, which is how
+		// the AWS SDKs encode them.
+		header.Set(s3MetaPrefix+k, mime.QEncoding.Encode("utf-8", v))
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, path, nil, header, tmp, written, checksum)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, path string) (*Blob, error) {
+	info, err := s.Stat(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &Blob{
+		Info:           *info,
+		ReadSeekCloser: &s3ObjectReader{ctx: ctx, store: s, path: path, size: info.Size},
+	}, nil
+}
+
+// Delete checks the object exists before deleting it, as S3 does not report whether deleted objects existed.
+func (s *S3Store) Delete(ctx context.Context, path string) error {
+	if _, err := s.Stat(ctx, path); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, path, nil, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List only includes the path, size and modification time of each blob, as S3 does not list the content type
+// or metadata of objects. Use Stat to get the other attributes.
+func (s *S3Store) List(ctx context.Context, prefix string) ([]Info, error) {
+	var infos []Info
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil, 0, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode list response: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			infos = append(infos, Info{Path: c.Key, Size: c.Size, UpdatedAt: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return infos, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (s *S3Store) Stat(ctx context.Context, path string) (*Info, error) {
+	if err := validatePath(path); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodHead, path, nil, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	info := &Info{
+		Path:        path,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+		Metadata:    make(map[string]string),
+	}
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.UpdatedAt = lastModified
+	}
+	for name, values := range resp.Header {
+		key, ok := strings.CutPrefix(name, s3MetaPrefix)
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if key == s3SHA256Key {
+			info.SHA256 = values[0]
+			continue
+		}
+		value, err := s3MetadataDecoder.DecodeHeader(values[0])
+		if err != nil {
+			value = values[0]
+		}
+		info.Metadata[strings.ToLower(key)] = value
+	}
+	return info, nil
+}
+
+var s3MetadataDecoder = new(mime.WordDecoder)
+
+// validS3MetadataKey reports whether the metadata key can be sent in a header name, which S3 lower cases.
+func validS3MetadataKey(k string) bool {
+	if k == "" || strings.EqualFold(k, s3SHA256Key) {
+		return false
+	}
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		if !('A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// do sends a signed request for the object at path, or for the bucket if path is empty.
+// ErrNotFound is returned if the object does not exist and an error for any other unsuccessful response.
+func (s *S3Store) do(
+	ctx context.Context,
+	method, path string,
+	query url.Values,
+	header http.Header,
+	body io.Reader,
+	contentLength int64,
+	payloadHash string,
+) (*http.Response, error) {
+	u := *s.endpoint
+	escapedPath := strings.TrimSuffix(u.EscapedPath(), "/")
+	if s.config.UsePathStyle {
+		escapedPath += "/" + s3URIEncode(s.config.Bucket, false)
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+	}
+	escapedPath += "/" + s3URIEncode(path, true)
+
+	unescapedPath, err := url.PathUnescape(escapedPath)
+	if err != nil {
+		return nil, err
+	}
+	u.Path, u.RawPath = unescapedPath, escapedPath
+	u.RawQuery = s3CanonicalQuery(query)
+
+	if body != nil && contentLength == 0 {
+		// An empty body of an unknown type is sent chunked, which S3 rejects with 411 Length Required.
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = contentLength
+	}
+	signV4(req, payloadHash, s.config.Region, s.config.AccessKeyID, s.config.SecretAccessKey, time.Now())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending S3 request: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && path != "":
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("S3 responded with %d: %s", resp.StatusCode, msg)
+	}
+	return resp, nil
+}
+
+// s3ObjectReader reads an object with ranged GET requests, starting a new request after each seek.
+// No request is made until the object is read, so seeking to determine the size is free.
+type s3ObjectReader struct {
+	ctx    context.Context
+	store  *S3Store
+	path   string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *s3ObjectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		header := http.Header{"Range": {"bytes=" + strconv.FormatInt(r.offset, 10) + "-"}}
+		resp, err := r.store.do(r.ctx, http.MethodGet, r.path, nil, header, nil, 0, emptyPayloadHash)
+		if err != nil {
+			return 0, err
+		}
+		r.body = resp.Body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if errors.Is(err, io.EOF) && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *s3ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	if offset != r.offset {
+		r.closeBody()
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *s3ObjectReader) Close() error {
+	r.closeBody()
+	return nil
+}
+
+func (r *s3ObjectReader) closeBody() {
+	if r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+}
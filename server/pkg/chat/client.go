@@ -13,23 +13,28 @@ const pongWait = 10 * time.Second
 // ClientList represents a list of Client.
 type ClientList map[*Client]struct{}
 
-// Client represents a user within a Room.
-// A Room can have only one instance of each Client
+// ParticipantClients represents the clients of each participant, keyed by participant ID.
+type ParticipantClients map[string]ClientList
+
+// Client represents a single device of a user within a Room.
+// A user can have multiple clients in a Room, one for each device they have the conversation open on.
 // A user can be in multiple rooms, represented by different clients.
 type Client struct {
-	room   *Room
-	socket *websocket.Conn
-	egress chan Event
-	logger *slog.Logger
+	participantID string
+	room          *Room
+	socket        *websocket.Conn
+	egress        chan Event
+	logger        *slog.Logger
 }
 
-// NewClient creates an instance of a Client.
-func NewClient(ws *websocket.Conn, room *Room) *Client {
+// NewClient creates an instance of a Client for the given participant.
+func NewClient(ws *websocket.Conn, room *Room, participantID string) *Client {
 	return &Client{
-		room:   room,
-		socket: ws,
-		egress: make(chan Event, messageBufferSize),
-		logger: room.logger,
+		participantID: participantID,
+		room:          room,
+		socket:        ws,
+		egress:        make(chan Event, messageBufferSize),
+		logger:        room.logger.With("participantID", participantID),
 	}
 }
 
@@ -16,6 +16,9 @@ const (
 	EventTypeSendMessage   EventType = "send_message"
 	EventTypeNewMessage    EventType = "new_message"
 	EventTypeTyping        EventType = "typing"
+	EventTypeMarkRead      EventType = "mark_read"
+	EventTypeMessagesRead  EventType = "messages_read"
+	EventTypePresence      EventType = "presence"
 )
 
 var emojiKeyLookup = map[string]string{
@@ -56,6 +59,57 @@ type NewEmojiReactEvent struct {
 	Emoji     *string `json:"emoji"`
 }
 
+type TypingState string
+
+const (
+	TypingStateStarted TypingState = "started"
+	TypingStateStopped TypingState = "stopped"
+)
+
+// TypingEvent indicates that a participant has started or stopped typing.
+// The ParticipantID is always set by the server; any value sent by the client is ignored.
+type TypingEvent struct {
+	ParticipantID string      `json:"participantId"`
+	State         TypingState `json:"state"`
+}
+
+type MarkReadEvent struct {
+	MessageID int64 `json:"messageId"`
+}
+
+// MessagesReadEvent indicates that a participant has read all messages sent to them up to and including MessageID.
+// It is sent to every client in the room so that the participant's other devices can sync their read state.
+type MessagesReadEvent struct {
+	ParticipantID string    `json:"participantId"`
+	MessageID     int64     `json:"messageId"`
+	ReadAt        time.Time `json:"readAt"`
+}
+
+type ParticipantPresence struct {
+	ParticipantID string `json:"participantId"`
+	DeviceCount   int    `json:"deviceCount"`
+}
+
+// PresenceEvent describes who is currently connected to a room.
+// ParticipantCount counts each participant once, regardless of how many devices they have connected.
+type PresenceEvent struct {
+	ParticipantCount int                   `json:"participantCount"`
+	DeviceCount      int                   `json:"deviceCount"`
+	Participants     []ParticipantPresence `json:"participants"`
+}
+
+// newEvent creates an Event of the given type with the payload marshalled as JSON.
+func newEvent(t EventType, payload any) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		Type:    t,
+		Payload: data,
+	}, nil
+}
+
 type EventHandler func(e Event, c *Client) error
 
 type eventHandlers struct {
@@ -85,12 +139,14 @@ func (h *eventHandlers) SendMessageHandler(e Event, c *Client) error {
 	broadcast.SendMessageEvent = msgEvent
 	broadcast.Timestamp = time.Now()
 
-	messageID, err := h.room.manager.callbacks.HandleNewMessage(h.room.key.ConversationID, broadcast)
+	messageID, err := h.room.manager.callbacks.HandleNewMessage(h.room.key.ConversationID, broadcast, h.room.connectedParticipantIDs())
 	if err != nil {
 		logger.Error("error persisting message in database", "error", err)
 	}
 
 	broadcast.ID = messageID
+	h.room.typing.stop(c.participantID)
+
 	data, err := json.Marshal(broadcast)
 	if err != nil {
 		return fmt.Errorf("could not marshal new message: %w", err)
@@ -100,9 +156,7 @@ func (h *eventHandlers) SendMessageHandler(e Event, c *Client) error {
 	outgoingEvent.Type = EventTypeNewMessage
 	outgoingEvent.Payload = data
 
-	for client := range c.room.clients {
-		client.egress <- outgoingEvent
-	}
+	h.room.broadcast(outgoingEvent, everyClient)
 	return nil
 }
 
@@ -143,18 +197,57 @@ func (h *eventHandlers) EmojiReactHandler(e Event, c *Client) error {
 	}
 
 	outgoingEvent.Payload = data
-	for client := range c.room.clients {
-		client.egress <- outgoingEvent
+	h.room.broadcast(outgoingEvent, everyClient)
+	return nil
+}
+
+// MarkReadHandler handles a participant reading messages on one of their devices.
+//   - the messages sent to the participant up to and including the message are marked as read in the database,
+//     in direct conversations only, as read state is not stored per member of a group.
+//   - an event is sent to all room clients so the participant's other devices and the sender see the read state.
+func (h *eventHandlers) MarkReadHandler(e Event, c *Client) error {
+	var markReadEvent MarkReadEvent
+	if err := json.Unmarshal(e.Payload, &markReadEvent); err != nil {
+		return fmt.Errorf("bad payload for %v event: %w", EventTypeMarkRead, err)
 	}
+
+	if err := h.room.manager.callbacks.HandleMessageRead(markReadEvent.MessageID, c.participantID); err != nil {
+		return fmt.Errorf("could not mark message read: %w", err)
+	}
+
+	outgoingEvent, err := newEvent(EventTypeMessagesRead, MessagesReadEvent{
+		ParticipantID: c.participantID,
+		MessageID:     markReadEvent.MessageID,
+		ReadAt:        time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("could not marshal messages read event: %w", err)
+	}
+
+	h.room.broadcast(outgoingEvent, everyClient)
 	return nil
 }
 
+// SendTypingIndication handles typing events sent by a client within a room.
+//   - the participant is stamped from the client rather than trusted from the payload.
+//   - a started event is only broadcast when the participant was not already typing.
+//   - the typing state expires if no further events are received, so a disconnected client cannot leave it stuck.
 func (h *eventHandlers) SendTypingIndication(e Event, c *Client) error {
-	for client := range c.room.clients {
-		if client != c {
-			client.egress <- e
+	// An empty payload is treated as started for clients that only signal activity.
+	typingEvent := TypingEvent{State: TypingStateStarted}
+	if len(e.Payload) > 0 {
+		if err := json.Unmarshal(e.Payload, &typingEvent); err != nil {
+			return fmt.Errorf("bad payload for %v event: %w", EventTypeTyping, err)
 		}
 	}
 
+	switch typingEvent.State {
+	case TypingStateStarted, "":
+		h.room.typing.start(c.participantID)
+	case TypingStateStopped:
+		h.room.typing.stop(c.participantID)
+	default:
+		return fmt.Errorf("bad payload for %v event: unknown state %q", EventTypeTyping, typingEvent.State)
+	}
 	return nil
 }
@@ -17,8 +17,8 @@ type RoomIdentifier interface {
 }
 
 type RoomParticipant interface {
-	PrimaryParticipantID() string
-	SecondaryParticipantID() string
+	// HasParticipant returns true if the participant is a member of the room.
+	HasParticipant(participantID string) bool
 }
 
 type RoomDetail interface {
@@ -46,17 +46,27 @@ type ManagerCallbacks struct {
 	//   - A RoomDetail instance containing information about the room.
 	//   - An error if the room could not be retrieved or created.
 	HandleRoomCreation func(identifier uuid.UUID, secondaryParticipantID string) (RoomDetail, error)
+	// HandleGroupRoomLookup is a callback triggered when a group room needs to be retrieved.
+	// Group rooms are not created on demand; the callback should return an error if the group does not exist.
+	//
+	// Returns:
+	//   - A RoomDetail instance containing information about the group room and its members.
+	//   - An error if the group room could not be retrieved.
+	HandleGroupRoomLookup func(identifier uuid.UUID, participantID string) (RoomDetail, error)
 	// HandleNewMessage is a callback invoked when a new message is sent in a conversation.
 	// If you are persisting messages in a database, you should persist the message in this function and return the message ID.
+	// The message is broadcast to every client connected to the room, so participants who are not connected
+	// may be notified of the message by other means.
 	//
 	// Parameters:
 	//   - conversationID: The ID of the conversation containing the message.
 	//   - message: The newly created message.
+	//   - connectedParticipantIDs: The IDs of the participants with at least one client connected to the room.
 	//
 	// Returns:
 	//   - The ID of the newly created message.
 	//   - An error if the message could not be created.
-	HandleNewMessage func(conversationID int64, message NewMessageEvent) (int64, error)
+	HandleNewMessage func(conversationID int64, message NewMessageEvent, connectedParticipantIDs []string) (int64, error)
 	// HandleEmojiUpdate is a callback allowing you to update the emoji reaction for a specific message.
 	//
 	// Parameters:
@@ -67,6 +77,16 @@ type ManagerCallbacks struct {
 	// Returns:
 	//   - An error if the message could not be updated with the emoji key.
 	HandleEmojiUpdate func(conversationID, messageID int64, emojiKey *string) error
+	// HandleMessageRead is a callback invoked when a participant has read messages on one of their devices.
+	// All messages sent to the participant up to and including the given message should be marked as read.
+	//
+	// Parameters:
+	//   - messageID: The ID of the most recent message the participant has read.
+	//   - participantID: The ID of the participant who read the message.
+	//
+	// Returns:
+	//   - An error if the messages could not be marked as read.
+	HandleMessageRead func(messageID int64, participantID string) error
 	// FetchHistoricalMessages is a callback that retrieves historical messages for a given conversation.
 	//
 	// Parameters:
@@ -111,9 +131,28 @@ func (m *Manager) GetOrCreateRoom(identifier uuid.UUID, participantID string) (*
 	if err != nil {
 		return nil, err
 	}
+	return m.joinRoom(conversation, participantID)
+}
+
+// GetGroupRoom gets the group room for the identifier, creating the Room if no one has joined it yet.
+// The group conversation must already exist and the participant must be a member of it.
+func (m *Manager) GetGroupRoom(identifier uuid.UUID, participantID string) (*Room, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	conversation, err := m.callbacks.HandleGroupRoomLookup(identifier, participantID)
+	if err != nil {
+		return nil, err
+	}
+	return m.joinRoom(conversation, participantID)
+}
 
+// joinRoom validates that the participant is a member of the conversation and returns its Room,
+// creating and running the Room if it does not already exist.
+// The caller must hold the Manager lock.
+func (m *Manager) joinRoom(conversation RoomDetail, participantID string) (*Room, error) {
 	// Validate that the joining participant is a member of the Room
-	if participantID != conversation.PrimaryParticipantID() && participantID != conversation.SecondaryParticipantID() {
+	if !conversation.HasParticipant(participantID) {
 		m.logger.Error("participant not member", "joiningParticipantID", participantID, "conversation", conversation)
 		return nil, ErrUnauthorized
 	}
@@ -128,3 +167,16 @@ func (m *Manager) GetOrCreateRoom(identifier uuid.UUID, participantID string) (*
 	go r.run()
 	return r, nil
 }
+
+// DisconnectParticipant closes the connections of every client of the participant in the room of the conversation,
+// if anyone has joined it. It must be called when a participant is removed from a conversation, as membership is
+// only checked when joining the room.
+func (m *Manager) DisconnectParticipant(conversationID int64, identifier uuid.UUID, participantID string) {
+	m.RLock()
+	r, ok := m.rooms[NewRoomKey(conversationID, identifier).String()]
+	m.RUnlock()
+	if !ok {
+		return
+	}
+	r.disconnectParticipant(participantID)
+}
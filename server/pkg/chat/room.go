@@ -48,7 +48,7 @@ type Room struct {
 	manager *Manager
 	logger  *slog.Logger
 
-	clients ClientList
+	clients ParticipantClients
 	sync.RWMutex
 
 	join    chan *Client
@@ -56,6 +56,7 @@ type Room struct {
 	forward chan Event
 
 	handlers *eventHandlers
+	typing   *typingTracker
 }
 
 // NewRoom instantiates a new Room.
@@ -66,18 +67,19 @@ func NewRoom(conversationID int64, identifier uuid.UUID, manager *Manager) *Room
 		manager: manager,
 		logger:  manager.logger.With("roomKey", roomKey.String()),
 
-		clients: make(map[*Client]struct{}),
+		clients: make(ParticipantClients),
 		join:    make(chan *Client),
 		leave:   make(chan *Client),
 		forward: make(chan Event),
 	}
 	handlers := newEventHandlers(room)
 	room.handlers = handlers
+	room.typing = newTypingTracker(room)
 	return room
 }
 
-// ServeWS takes the initial HTTP request and updates it to a WebSocket connection.
-func (r *Room) ServeWS(w http.ResponseWriter, req *http.Request) {
+// ServeWS takes the initial HTTP request and updates it to a WebSocket connection for the given participant.
+func (r *Room) ServeWS(w http.ResponseWriter, req *http.Request, participantID string) {
 	roomID := req.URL.Query().Get("r")
 	if roomID == "" {
 		http.Error(w, "Room key required", http.StatusBadRequest)
@@ -90,7 +92,7 @@ func (r *Room) ServeWS(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	client := NewClient(socket, r)
+	client := NewClient(socket, r, participantID)
 
 	r.join <- client
 	defer func() { r.leave <- client }()
@@ -107,6 +109,8 @@ func (r *Room) HandleEvent(e Event, c *Client) error {
 		return r.handlers.EmojiReactHandler(e, c)
 	case EventTypeTyping:
 		return r.handlers.SendTypingIndication(e, c)
+	case EventTypeMarkRead:
+		return r.handlers.MarkReadHandler(e, c)
 	default:
 		return ErrUnsupportedEventType
 	}
@@ -122,39 +126,142 @@ func (r *Room) run() {
 			if err := r.EgressHistoricalMessages(client); err != nil {
 				r.logger.Error("failed to egress historical messages", "error", err)
 			}
+			r.broadcastPresence()
 		case client := <-r.leave:
 			r.logger.Debug("leave", "Client", client)
 			r.removeClient(client)
 		case message := <-r.forward:
 			r.logger.Debug("forward", "roomID", r.key, "msg", message)
-			for client := range r.clients {
-				client.egress <- message
-			}
+			r.broadcast(message, everyClient)
 		}
 	}
 }
 
-// addClient adds a new Client (user) to the room.
-// The client will be overridden if they already exist.
+// addClient adds a new Client (device) to the room.
+// A participant may have any number of clients in the room at once.
 func (r *Room) addClient(client *Client) {
 	r.Lock()
 	defer r.Unlock()
-	r.clients[client] = struct{}{}
+
+	if _, ok := r.clients[client.participantID]; !ok {
+		r.clients[client.participantID] = make(ClientList)
+	}
+	r.clients[client.participantID][client] = struct{}{}
 }
 
-// removeClient removes a client (user) from the room if they exist.
+// removeClient removes a client (device) from the room if they exist.
 // Nothing happens if the client is not in the room.
+// The participant is only considered to have left the room once their last client has been removed.
 func (r *Room) removeClient(client *Client) {
 	r.Lock()
-	defer r.Unlock()
-
-	if _, ok := r.clients[client]; ok {
+	participantClients := r.clients[client.participantID]
+	_, ok := participantClients[client]
+	if ok {
 		close(client.egress)
 		if err := client.socket.Close(); err != nil {
 			r.logger.Error("error closing client socket", "client", client, "error", err)
 		}
-		delete(r.clients, client)
+		delete(participantClients, client)
+	}
+	participantLeft := ok && len(participantClients) == 0
+	if participantLeft {
+		delete(r.clients, client.participantID)
+	}
+	r.Unlock()
+
+	if !ok {
+		return
+	}
+	if participantLeft {
+		r.typing.stop(client.participantID)
+	}
+	r.broadcastPresence()
+}
+
+// disconnectParticipant removes every client of the participant from the room, closing their connections.
+func (r *Room) disconnectParticipant(participantID string) {
+	r.RLock()
+	clients := make([]*Client, 0, len(r.clients[participantID]))
+	for client := range r.clients[participantID] {
+		clients = append(clients, client)
+	}
+	r.RUnlock()
+
+	for _, client := range clients {
+		r.removeClient(client)
+	}
+}
+
+// everyClient is a broadcast filter including every client in the room.
+func everyClient(*Client) bool {
+	return true
+}
+
+// broadcast sends the event to every client in the room for which include returns true.
+// A client whose egress buffer is full is lagging too far behind to catch up, so it is removed from the room
+// rather than blocking the room, and can reconnect to receive the history it missed.
+func (r *Room) broadcast(e Event, include func(c *Client) bool) {
+	var lagging []*Client
+	r.RLock()
+	for _, participantClients := range r.clients {
+		for client := range participantClients {
+			if !include(client) {
+				continue
+			}
+			select {
+			case client.egress <- e:
+			default:
+				lagging = append(lagging, client)
+			}
+		}
+	}
+	r.RUnlock()
+
+	for _, client := range lagging {
+		r.logger.Warn("removing lagging client", "client", client)
+		r.removeClient(client)
+	}
+}
+
+// presence returns the participants currently in the room along with the number of devices each has connected.
+func (r *Room) presence() PresenceEvent {
+	r.RLock()
+	defer r.RUnlock()
+
+	p := PresenceEvent{
+		Participants: make([]ParticipantPresence, 0, len(r.clients)),
+	}
+	for participantID, participantClients := range r.clients {
+		p.Participants = append(p.Participants, ParticipantPresence{
+			ParticipantID: participantID,
+			DeviceCount:   len(participantClients),
+		})
+		p.DeviceCount += len(participantClients)
+	}
+	p.ParticipantCount = len(p.Participants)
+	return p
+}
+
+// connectedParticipantIDs returns the IDs of the participants with at least one client connected to the room.
+func (r *Room) connectedParticipantIDs() []string {
+	r.RLock()
+	defer r.RUnlock()
+
+	ids := make([]string, 0, len(r.clients))
+	for participantID := range r.clients {
+		ids = append(ids, participantID)
+	}
+	return ids
+}
+
+// broadcastPresence sends the current presence of the room to every client.
+func (r *Room) broadcastPresence() {
+	e, err := newEvent(EventTypePresence, r.presence())
+	if err != nil {
+		r.logger.Error("could not marshal presence event", "error", err)
+		return
 	}
+	r.broadcast(e, everyClient)
 }
 
 // EgressHistoricalMessages sends historical messages to a specific client (user). A client belongs to a specific room.
@@ -0,0 +1,78 @@
+package chat
+
+import (
+	"sync"
+	"time"
+)
+
+// typingExpiry is how long a participant is considered to be typing after their last typing event.
+// Clients are expected to send typing events more frequently than this while the participant is typing.
+const typingExpiry = 5 * time.Second
+
+// typingTracker tracks which participants in a room are currently typing.
+//
+// Repeated started events from a participant who is already typing are debounced; only the transition
+// from not typing to typing is broadcast. A stopped event is broadcast when the participant explicitly
+// stops, sends a message, leaves the room, or when no typing event has been received within typingExpiry.
+type typingTracker struct {
+	room   *Room
+	expiry time.Duration
+
+	timers map[string]*time.Timer
+	sync.Mutex
+}
+
+func newTypingTracker(room *Room) *typingTracker {
+	return &typingTracker{
+		room:   room,
+		expiry: typingExpiry,
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+// start marks the participant as typing, broadcasting a started event if they were not already typing.
+func (t *typingTracker) start(participantID string) {
+	t.Lock()
+	if timer, ok := t.timers[participantID]; ok {
+		timer.Reset(t.expiry)
+		t.Unlock()
+		return
+	}
+	t.timers[participantID] = time.AfterFunc(t.expiry, func() {
+		t.stop(participantID)
+	})
+	t.Unlock()
+
+	t.broadcast(participantID, TypingStateStarted)
+}
+
+// stop marks the participant as no longer typing, broadcasting a stopped event if they were typing.
+func (t *typingTracker) stop(participantID string) {
+	t.Lock()
+	timer, ok := t.timers[participantID]
+	if !ok {
+		t.Unlock()
+		return
+	}
+	timer.Stop()
+	delete(t.timers, participantID)
+	t.Unlock()
+
+	t.broadcast(participantID, TypingStateStopped)
+}
+
+// broadcast sends the typing state to every client in the room other than those of the typing participant.
+func (t *typingTracker) broadcast(participantID string, state TypingState) {
+	e, err := newEvent(EventTypeTyping, TypingEvent{
+		ParticipantID: participantID,
+		State:         state,
+	})
+	if err != nil {
+		t.room.logger.Error("could not marshal typing event", "error", err)
+		return
+	}
+
+	t.room.broadcast(e, func(c *Client) bool {
+		return c.participantID != participantID
+	})
+}
@@ -0,0 +1,120 @@
+// Package imaging decodes uploaded images and resizes them into renditions suitable for serving.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format, expected JPEG, PNG or WebP")
+	ErrTooLarge          = errors.New("image dimensions too large")
+)
+
+const (
+	// maxPixels is the largest image, in pixels, that will be decoded. A decoded image takes up to 4 bytes per
+	// pixel, so this bounds the memory used by each upload to around 80 MB while allowing 20 megapixel photos.
+	// The dimensions are checked before decoding so small files claiming huge dimensions are rejected cheaply.
+	maxPixels = 20_000_000
+	// jpegQuality is the quality renditions are encoded with.
+	jpegQuality = 85
+	// ContentType is the content type of every rendition.
+	ContentType = "image/jpeg"
+)
+
+// Rendition is a size an image is resized to.
+type Rendition struct {
+	Name string
+	// MaxSize is the length of the longest side of the rendition. Images are never enlarged.
+	MaxSize int
+}
+
+// Output is an encoded rendition of an image.
+type Output struct {
+	Rendition Rendition
+	Data      []byte
+	Width     int
+	Height    int
+}
+
+// Process decodes a JPEG, PNG or WebP image and encodes each of the renditions as a JPEG.
+//
+// The EXIF orientation of JPEG images is applied to the renditions. As the renditions are re-encoded without any
+// of the metadata of the original image, such as the GPS location the photo was taken at, none of it is retained.
+// Transparent areas are flattened onto white.
+func Process(data []byte, renditions []Rendition) ([]Output, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if format != "jpeg" && format != "png" && format != "webp" {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+
+	orientation := orientationNormal
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	outputs := make([]Output, len(renditions))
+	for i, r := range renditions {
+		img := orient(resize(src, r.MaxSize, orientation.swapsDimensions()), orientation)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, fmt.Errorf("error encoding %s rendition: %w", r.Name, err)
+		}
+		outputs[i] = Output{
+			Rendition: r,
+			Data:      buf.Bytes(),
+			Width:     img.Bounds().Dx(),
+			Height:    img.Bounds().Dy(),
+		}
+	}
+	return outputs, nil
+}
+
+// resize scales the image so its longest side is no longer than maxSize, flattening it onto white.
+// The dimensions are calculated as if they were swapped when the image is going to be rotated by a quarter turn,
+// so the longest side of the rotated image is also no longer than maxSize.
+func resize(src image.Image, maxSize int, swapped bool) *image.RGBA {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if swapped {
+		w, h = h, w
+	}
+
+	if w > maxSize || h > maxSize {
+		if w >= h {
+			h = max(1, h*maxSize/w)
+			w = maxSize
+		} else {
+			w = max(1, w*maxSize/h)
+			h = maxSize
+		}
+	}
+	if swapped {
+		w, h = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
@@ -0,0 +1,134 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// orientation is the EXIF orientation of an image, describing how the stored pixels must be transformed
+// for the image to be displayed the right way up.
+type orientation int
+
+const (
+	orientationNormal         orientation = 1
+	orientationFlipHorizontal orientation = 2
+	orientationRotate180      orientation = 3
+	orientationFlipVertical   orientation = 4
+	orientationTranspose      orientation = 5
+	orientationRotate90       orientation = 6
+	orientationTransverse     orientation = 7
+	orientationRotate270      orientation = 8
+)
+
+// exifOrientationTag is the EXIF tag of the orientation.
+const exifOrientationTag = 0x0112
+
+// swapsDimensions reports whether the orientation rotates the image by a quarter turn.
+func (o orientation) swapsDimensions() bool {
+	return o >= orientationTranspose && o <= orientationRotate270
+}
+
+// jpegOrientation reads the orientation from the EXIF data of a JPEG image,
+// returning orientationNormal if there is none or it cannot be read.
+func jpegOrientation(data []byte) orientation {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return orientationNormal
+	}
+
+	// Walk the segments before the image data looking for the APP1 segment containing the EXIF data.
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return orientationNormal
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			return orientationNormal
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return orientationNormal
+}
+
+// tiffOrientation reads the orientation from the first IFD of the TIFF structure EXIF data is stored in.
+func tiffOrientation(tiff []byte) orientation {
+	if len(tiff) < 8 {
+		return orientationNormal
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return orientationNormal
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return orientationNormal
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) != exifOrientationTag {
+			continue
+		}
+		// The orientation is a SHORT stored in the first two bytes of the value.
+		o := orientation(order.Uint16(tiff[entry+8:]))
+		if o < orientationNormal || o > orientationRotate270 {
+			return orientationNormal
+		}
+		return o
+	}
+	return orientationNormal
+}
+
+// orient transforms the image so it is displayed the right way up.
+func orient(src *image.RGBA, o orientation) *image.RGBA {
+	if o == orientationNormal {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if o.swapsDimensions() {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case orientationFlipHorizontal:
+				dx, dy = w-1-x, y
+			case orientationRotate180:
+				dx, dy = w-1-x, h-1-y
+			case orientationFlipVertical:
+				dx, dy = x, h-1-y
+			case orientationTranspose:
+				dx, dy = y, x
+			case orientationRotate90:
+				dx, dy = h-1-y, x
+			case orientationTransverse:
+				dx, dy = h-1-y, w-1-x
+			case orientationRotate270:
+				dx, dy = y, w-1-x
+			}
+			si := src.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
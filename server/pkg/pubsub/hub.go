@@ -0,0 +1,88 @@
+package pubsub
+
+import (
+	"sync"
+)
+
+// subscriberBufferSize is the number of messages buffered for each subscriber.
+// Subscribers whose buffer is full are unsubscribed, closing their channel, so that a slow subscriber cannot block
+// publishers and knows it has missed messages.
+const subscriberBufferSize = 16
+
+// Hub is an in-process publish/subscribe hub where messages are published to the subscribers of a topic.
+type Hub[T any] struct {
+	subscribers map[string]map[*subscriber[T]]struct{}
+	sync.RWMutex
+}
+
+type subscriber[T any] struct {
+	ch   chan T
+	once sync.Once
+}
+
+// NewHub creates an instance of a new Hub.
+func NewHub[T any]() *Hub[T] {
+	return &Hub[T]{
+		subscribers: make(map[string]map[*subscriber[T]]struct{}),
+	}
+}
+
+// Subscribe subscribes to messages published to the topic.
+// The returned function must be called to unsubscribe once the subscriber is no longer interested in the topic;
+// the channel is closed when it is called. The channel is also closed, after the messages already buffered,
+// if the subscriber falls so far behind that a message cannot be delivered to it.
+func (h *Hub[T]) Subscribe(topic string) (<-chan T, func()) {
+	h.Lock()
+	defer h.Unlock()
+
+	sub := &subscriber[T]{ch: make(chan T, subscriberBufferSize)}
+	if _, ok := h.subscribers[topic]; !ok {
+		h.subscribers[topic] = make(map[*subscriber[T]]struct{})
+	}
+	h.subscribers[topic][sub] = struct{}{}
+
+	unsubscribe := func() {
+		h.Lock()
+		defer h.Unlock()
+		h.remove(topic, sub)
+	}
+	return sub.ch, unsubscribe
+}
+
+// Publish sends the message to every subscriber of the topic, unsubscribing those whose buffer is full.
+// Returns the number of subscribers the message was delivered to.
+func (h *Hub[T]) Publish(topic string, msg T) int {
+	h.RLock()
+	delivered := 0
+	var overflowed []*subscriber[T]
+	for sub := range h.subscribers[topic] {
+		select {
+		case sub.ch <- msg:
+			delivered++
+		default:
+			overflowed = append(overflowed, sub)
+		}
+	}
+	h.RUnlock()
+
+	if len(overflowed) > 0 {
+		h.Lock()
+		defer h.Unlock()
+		for _, sub := range overflowed {
+			h.remove(topic, sub)
+		}
+	}
+	return delivered
+}
+
+// remove unsubscribes the subscriber from the topic and closes its channel. It must be called with the lock held,
+// and may be called more than once for the same subscriber.
+func (h *Hub[T]) remove(topic string, sub *subscriber[T]) {
+	delete(h.subscribers[topic], sub)
+	if len(h.subscribers[topic]) == 0 {
+		delete(h.subscribers, topic)
+	}
+	sub.once.Do(func() {
+		close(sub.ch)
+	})
+}
@@ -0,0 +1,66 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	minReconnectInterval = 10 * time.Second
+	maxReconnectInterval = time.Minute
+	listenerPingInterval = 90 * time.Second
+)
+
+// PostgresListener listens for notifications sent with Postgres NOTIFY on a single channel.
+// It allows events raised by any instance connected to the database to be received by every instance.
+type PostgresListener struct {
+	connectionString string
+	channel          string
+	logger           *slog.Logger
+}
+
+// NewPostgresListener creates a listener for the given Postgres NOTIFY channel.
+func NewPostgresListener(connectionString, channel string, logger *slog.Logger) *PostgresListener {
+	return &PostgresListener{
+		connectionString: connectionString,
+		channel:          channel,
+		logger:           logger.With("channel", channel),
+	}
+}
+
+// Listen calls handle with the payload of each notification received on the channel until the context is cancelled.
+// The connection is re-established automatically if it is lost; notifications sent while disconnected are missed.
+func (l *PostgresListener) Listen(ctx context.Context, handle func(payload string)) error {
+	listener := pq.NewListener(l.connectionString, minReconnectInterval, maxReconnectInterval, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			l.logger.Error("postgres listener event", "event", ev, "error", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(l.channel); err != nil {
+		return fmt.Errorf("could not listen on channel %s: %w", l.channel, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n := <-listener.Notify:
+			// A nil notification is sent when the connection has been re-established.
+			if n == nil {
+				l.logger.Warn("postgres listener reconnected, notifications may have been missed")
+				continue
+			}
+			handle(n.Extra)
+		case <-time.After(listenerPingInterval):
+			if err := listener.Ping(); err != nil {
+				l.logger.Error("postgres listener ping failed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import "time"
+
+// Schedule determines when a job is due.
+type Schedule interface {
+	// Last returns the most recent time at or before t that the job was due.
+	Last(t time.Time) time.Time
+}
+
+type every time.Duration
+
+// Every returns a schedule which is due at every multiple of d since the zero time, such as on the hour for time.Hour.
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+func (e every) Last(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Duration(e))
+}
+
+type daily struct {
+	hour int
+}
+
+// Daily returns a schedule which is due every day at the given hour in UTC.
+func Daily(hour int) Schedule {
+	return daily{hour: hour}
+}
+
+func (d daily) Last(t time.Time) time.Time {
+	t = t.UTC()
+	due := time.Date(t.Year(), t.Month(), t.Day(), d.hour, 0, 0, 0, time.UTC)
+	if due.After(t) {
+		due = due.AddDate(0, 0, -1)
+	}
+	return due
+}
+
+type weekly struct {
+	weekday time.Weekday
+	hour    int
+}
+
+// Weekly returns a schedule which is due every week on the given weekday at the given hour in UTC.
+func Weekly(weekday time.Weekday, hour int) Schedule {
+	return weekly{weekday: weekday, hour: hour}
+}
+
+func (w weekly) Last(t time.Time) time.Time {
+	t = t.UTC()
+	days := (int(t.Weekday()) - int(w.weekday) + 7) % 7
+	due := time.Date(t.Year(), t.Month(), t.Day()-days, w.hour, 0, 0, 0, time.UTC)
+	if due.After(t) {
+		due = due.AddDate(0, 0, -7)
+	}
+	return due
+}
@@ -0,0 +1,104 @@
+// Package scheduler runs jobs on a schedule, recording each run so that a job runs once per due time
+// no matter how many instances of the scheduler are running or how often they are restarted.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// pollInterval is how often the scheduler checks if any job is due.
+const pollInterval = time.Minute
+
+// Job is a unit of work run on a schedule.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Run performs the job for the time it was due.
+	// Runs which return an error are retried the next time the scheduler polls.
+	Run func(ctx context.Context, due time.Time) error
+}
+
+// RunStore records job runs.
+type RunStore interface {
+	// Claim records that the job is running for the due time, returning false if the run has already
+	// succeeded or is in progress elsewhere.
+	Claim(job string, due time.Time) (bool, error)
+	// Complete records the outcome of a claimed run; runErr is nil if the run succeeded.
+	Complete(job string, due time.Time, runErr error) error
+}
+
+// Scheduler runs each job once for every time it is due.
+// Only the most recent due time is run, so runs missed while no scheduler was running are not caught up.
+type Scheduler struct {
+	jobs   []Job
+	store  RunStore
+	logger *slog.Logger
+}
+
+func New(store RunStore, logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		store:  store,
+		logger: logger.With("component", "scheduler"),
+	}
+}
+
+// Add adds the job to the scheduler; jobs must be added before calling Run.
+func (s *Scheduler) Add(job Job) *Scheduler {
+	s.jobs = append(s.jobs, job)
+	return s
+}
+
+// Run runs jobs as they become due until the context is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	lastRun := make(map[string]time.Time)
+	for {
+		now := time.Now()
+		for _, job := range s.jobs {
+			due := job.Schedule.Last(now)
+			if lastRun[job.Name].Equal(due) {
+				continue
+			}
+			if s.run(ctx, job, due) {
+				lastRun[job.Name] = due
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run runs the job for the due time if it can be claimed, returning false if it should be attempted again.
+func (s *Scheduler) run(ctx context.Context, job Job, due time.Time) bool {
+	logger := s.logger.With("job", job.Name, "due", due)
+
+	claimed, err := s.store.Claim(job.Name, due)
+	if err != nil {
+		logger.Error("could not claim job run", "error", err)
+		return false
+	}
+	if !claimed {
+		return true
+	}
+
+	logger.Info("running job")
+	start := time.Now()
+	runErr := job.Run(ctx, due)
+	if err := s.store.Complete(job.Name, due, runErr); err != nil {
+		logger.Error("could not record job run", "error", err)
+	}
+	if runErr != nil {
+		logger.Error("job failed", "error", runErr, "duration", time.Since(start))
+		return false
+	}
+	logger.Info("job succeeded", "duration", time.Since(start))
+	return true
+}
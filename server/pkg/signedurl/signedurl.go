@@ -0,0 +1,110 @@
+// Package signedurl signs URL paths with HMAC-SHA256 so they can be served, until they expire,
+// to whoever holds the URL without any other authorization.
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrExpired          = errors.New("signed URL has expired")
+	ErrInvalidSignature = errors.New("invalid URL signature")
+)
+
+// MinKeyLength is the shortest key, in bytes, a Signer accepts.
+const MinKeyLength = 32
+
+const (
+	expiresParam   = "expires"
+	signatureParam = "signature"
+)
+
+// Signer signs paths with the first of its keys and verifies them with any of them, so keys can be rotated by
+// adding the new key first and removing the old key once the URLs signed with it have expired.
+type Signer struct {
+	keys [][]byte
+	ttl  time.Duration
+}
+
+// NewSigner creates a Signer whose signatures are valid for ttl.
+func NewSigner(keys [][]byte, ttl time.Duration) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys given")
+	}
+	for i, key := range keys {
+		if len(key) < MinKeyLength {
+			return nil, fmt.Errorf("signing key %d is shorter than %d bytes", i+1, MinKeyLength)
+		}
+	}
+	if ttl <= 0 {
+		return nil, errors.New("signed URLs must be valid for a positive duration")
+	}
+	return &Signer{keys: keys, ttl: ttl}, nil
+}
+
+// Sign returns the query parameters authorizing requests for the path until the signature expires.
+func (s *Signer) Sign(path string) url.Values {
+	return s.sign(path, time.Now().Add(s.ttl))
+}
+
+func (s *Signer) sign(path string, expires time.Time) url.Values {
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	return url.Values{
+		expiresParam:   {unix},
+		signatureParam: {base64.RawURLEncoding.EncodeToString(signature(s.keys[0], path, unix))},
+	}
+}
+
+// Verify checks the query parameters of a request for the path were returned by Sign for the same path,
+// returning ErrExpired if the signature has expired and ErrInvalidSignature if it is not valid.
+// It also returns when the signature expires, so responses can be cached until then.
+func (s *Signer) Verify(path string, query url.Values) (time.Time, error) {
+	return s.verify(path, query, time.Now())
+}
+
+func (s *Signer) verify(path string, query url.Values, now time.Time) (time.Time, error) {
+	unix := query.Get(expiresParam)
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidSignature
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(query.Get(signatureParam))
+	if err != nil {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	// The signature is checked before the expiry so the expiry of forged URLs is never reported.
+	valid := false
+	for _, key := range s.keys {
+		if hmac.Equal(sig, signature(key, path, unix)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	expires := time.Unix(seconds, 0)
+	if !now.Before(expires) {
+		return time.Time{}, ErrExpired
+	}
+	return expires, nil
+}
+
+// signature is the HMAC of the path and expiry. They are separated by a NUL byte, which cannot appear in either,
+// so no other path and expiry have the same input.
+func signature(key []byte, path, expires string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write([]byte(expires))
+	return h.Sum(nil)
+}
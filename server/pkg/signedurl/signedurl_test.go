@@ -0,0 +1,184 @@
+package signedurl
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte("o"), MinKeyLength)
+	newKey = bytes.Repeat([]byte("n"), MinKeyLength)
+)
+
+const path = "0b7e2a4c-6f5d-4a3b-9c1e-2d8f7a6b5c4d/photos/1/card"
+
+func newTestSigner(t *testing.T, keys ...[]byte) *Signer {
+	t.Helper()
+	s, err := NewSigner(keys, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestVerify(t *testing.T) {
+	s := newTestSigner(t, newKey)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(time.Hour)
+
+	got, err := s.verify(path, s.sign(path, expires), now)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !got.Equal(expires) {
+		t.Errorf("expires = %v, want %v", got, expires)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	s := newTestSigner(t, newKey)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	query := s.sign(path, now.Add(time.Hour))
+
+	tests := []struct {
+		name  string
+		path  string
+		query func() url.Values
+	}{
+		{
+			name:  "other path",
+			path:  "0b7e2a4c-6f5d-4a3b-9c1e-2d8f7a6b5c4d/photos/2/card",
+			query: func() url.Values { return query },
+		},
+		{
+			name:  "other rendition",
+			path:  "0b7e2a4c-6f5d-4a3b-9c1e-2d8f7a6b5c4d/photos/1/full",
+			query: func() url.Values { return query },
+		},
+		{
+			name:  "path prefix",
+			path:  "0b7e2a4c-6f5d-4a3b-9c1e-2d8f7a6b5c4d/photos/1",
+			query: func() url.Values { return query },
+		},
+		{
+			name: "extended expiry",
+			path: path,
+			query: func() url.Values {
+				q := url.Values{signatureParam: query[signatureParam]}
+				q.Set(expiresParam, strconv.FormatInt(now.Add(24*time.Hour).Unix(), 10))
+				return q
+			},
+		},
+		{
+			name: "path moved into expiry",
+			path: "0b7e2a4c-6f5d-4a3b-9c1e-2d8f7a6b5c4d/photos/1/car",
+			query: func() url.Values {
+				q := url.Values{signatureParam: query[signatureParam]}
+				q.Set(expiresParam, "d"+query.Get(expiresParam))
+				return q
+			},
+		},
+		{
+			name: "altered signature",
+			path: path,
+			query: func() url.Values {
+				sig := []byte(query.Get(signatureParam))
+				if sig[0] == 'A' {
+					sig[0] = 'B'
+				} else {
+					sig[0] = 'A'
+				}
+				return url.Values{expiresParam: query[expiresParam], signatureParam: {string(sig)}}
+			},
+		},
+		{
+			name:  "missing signature",
+			path:  path,
+			query: func() url.Values { return url.Values{expiresParam: query[expiresParam]} },
+		},
+		{
+			name:  "missing expiry",
+			path:  path,
+			query: func() url.Values { return url.Values{signatureParam: query[signatureParam]} },
+		},
+		{
+			name: "malformed signature",
+			path: path,
+			query: func() url.Values {
+				return url.Values{expiresParam: query[expiresParam], signatureParam: {"not base64!"}}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.verify(tt.path, tt.query(), now); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("verify = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	s := newTestSigner(t, newKey)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	query := s.sign(path, now)
+
+	if _, err := s.verify(path, query, now.Add(-time.Second)); err != nil {
+		t.Errorf("verify before expiry = %v, want nil", err)
+	}
+	for _, at := range []time.Time{now, now.Add(time.Second), now.Add(24 * time.Hour)} {
+		if _, err := s.verify(path, query, at); !errors.Is(err, ErrExpired) {
+			t.Errorf("verify at %v = %v, want ErrExpired", at, err)
+		}
+	}
+
+	// Expiry is only reported for genuine signatures, so forged URLs cannot be told apart from expired ones.
+	forged := url.Values{expiresParam: query[expiresParam], signatureParam: {"AAAA"}}
+	if _, err := s.verify(path, forged, now.Add(time.Second)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("verify of expired forged URL = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(time.Hour)
+
+	before := newTestSigner(t, oldKey)
+	during := newTestSigner(t, newKey, oldKey)
+	after := newTestSigner(t, newKey)
+
+	signedBefore := before.sign(path, expires)
+	signedDuring := during.sign(path, expires)
+
+	if _, err := during.verify(path, signedBefore, now); err != nil {
+		t.Errorf("URL signed with the old key is rejected while rotating: %v", err)
+	}
+	if _, err := during.verify(path, signedDuring, now); err != nil {
+		t.Errorf("URL signed while rotating is rejected while rotating: %v", err)
+	}
+	if _, err := after.verify(path, signedDuring, now); err != nil {
+		t.Errorf("URL signed while rotating is rejected after the old key is removed: %v", err)
+	}
+	if _, err := before.verify(path, signedDuring, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("URL signed while rotating is accepted by the old key alone: %v, want ErrInvalidSignature", err)
+	}
+	if _, err := after.verify(path, signedBefore, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("URL signed with the old key is accepted after it is removed: %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	if _, err := NewSigner(nil, time.Hour); err == nil {
+		t.Error("NewSigner without keys succeeded, want an error")
+	}
+	if _, err := NewSigner([][]byte{newKey, newKey[:MinKeyLength-1]}, time.Hour); err == nil {
+		t.Error("NewSigner with a short key succeeded, want an error")
+	}
+	if _, err := NewSigner([][]byte{newKey}, 0); err == nil {
+		t.Error("NewSigner with no TTL succeeded, want an error")
+	}
+}
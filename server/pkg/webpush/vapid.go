@@ -0,0 +1,105 @@
+package webpush
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// VAPIDKeys is the application server key pair used to identify the application server to push services (RFC 8292).
+type VAPIDKeys struct {
+	private *ecdsa.PrivateKey
+	public  []byte
+}
+
+// GenerateVAPIDKeys generates a new VAPID key pair.
+func GenerateVAPIDKeys() (*VAPIDKeys, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newVAPIDKeys(key.Bytes())
+}
+
+// ParseVAPIDKeys parses the base64url encoded private key, verifying it matches the base64url encoded public key.
+func ParseVAPIDKeys(publicKey, privateKey string) (*VAPIDKeys, error) {
+	d, err := decodeBase64(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: private key: %v", ErrInvalidKey, err)
+	}
+	keys, err := newVAPIDKeys(d)
+	if err != nil {
+		return nil, err
+	}
+	if publicKey != keys.PublicKey() {
+		return nil, fmt.Errorf("%w: public key does not match private key", ErrInvalidKey)
+	}
+	return keys, nil
+}
+
+func newVAPIDKeys(d []byte) (*VAPIDKeys, error) {
+	key, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("%w: private key: %v", ErrInvalidKey, err)
+	}
+
+	// The uncompressed public key is 0x04 || X || Y.
+	public := key.PublicKey().Bytes()
+	private := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}
+	return &VAPIDKeys{private: private, public: public}, nil
+}
+
+// PublicKey returns the base64url encoded public key, used as the applicationServerKey by browsers.
+func (k *VAPIDKeys) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(k.public)
+}
+
+// PrivateKey returns the base64url encoded private key.
+func (k *VAPIDKeys) PrivateKey() string {
+	return base64.RawURLEncoding.EncodeToString(k.private.D.FillBytes(make([]byte, 32)))
+}
+
+// authorization returns the value of the Authorization header for a request to the push service at audience.
+func (k *VAPIDKeys) authorization(audience, subject string, expiry time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"aud": audience,
+		"exp": expiry.Unix(),
+		"sub": subject,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	// ES256 signatures are the 32 byte big-endian R and S values concatenated.
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, k.PublicKey()), nil
+}
@@ -0,0 +1,265 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrSubscriptionGone is returned when the push service reports that the subscription has expired or
+	// been unsubscribed (404 or 410); the subscription should be removed.
+	ErrSubscriptionGone = errors.New("push subscription no longer valid")
+	ErrInvalidKey       = errors.New("invalid key")
+	// ErrInvalidEndpoint is returned for subscription endpoints which are not HTTPS URLs of a known push service.
+	ErrInvalidEndpoint = errors.New("invalid push subscription endpoint")
+)
+
+// pushServiceDomains are the domains of the push services used by browsers. Subscription endpoints must be on one
+// of them, or a subdomain, so that the server cannot be made to send requests to arbitrary hosts, such as those on
+// the internal network.
+var pushServiceDomains = []string{
+	// Chrome, Edge on Android and other Chromium based browsers.
+	"fcm.googleapis.com",
+	"android.googleapis.com",
+	// Firefox.
+	"push.services.mozilla.com",
+	// Safari.
+	"push.apple.com",
+	// Edge on Windows.
+	"notify.windows.com",
+}
+
+const (
+	// recordSize is the record size advertised in the aes128gcm header.
+	// Payloads are always encrypted as a single record, so this only needs to be larger than the payload.
+	recordSize = 4096
+	// maxPayloadSize is the largest payload that can be delivered by push services.
+	maxPayloadSize = 3993
+	defaultTTL     = 24 * time.Hour
+)
+
+// Subscription is a push subscription created by a browser with PushManager.subscribe.
+type Subscription struct {
+	Endpoint string
+	// P256dh is the base64url encoded public key of the user agent.
+	P256dh string
+	// Auth is the base64url encoded authentication secret of the user agent.
+	Auth string
+}
+
+// Options control how a push message is delivered.
+type Options struct {
+	// TTL is how long the push service should retain the message if the user agent is offline.
+	TTL time.Duration
+	// Urgency is one of very-low, low, normal or high.
+	Urgency string
+	// Topic allows a pending message to be replaced by a newer message with the same topic.
+	Topic string
+}
+
+// Client sends push messages to push services using VAPID for authentication.
+type Client struct {
+	keys       *VAPIDKeys
+	subject    string
+	httpClient *http.Client
+}
+
+// NewClient creates a Client signing requests with the VAPID keys.
+// The subject is a mailto: or https: URL the push service can use to contact the application server.
+func NewClient(keys *VAPIDKeys, subject string) *Client {
+	return &Client{
+		keys:       keys,
+		subject:    subject,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// ValidateEndpoint checks the endpoint is an HTTPS URL of a known push service, returning ErrInvalidEndpoint if not.
+func ValidateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil || (u.Port() != "" && u.Port() != "443") {
+		return ErrInvalidEndpoint
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range pushServiceDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+	}
+	return ErrInvalidEndpoint
+}
+
+// Send encrypts the payload for the subscription and sends it to the subscription's push service.
+// ErrSubscriptionGone is returned if the push service indicates the subscription is no longer valid,
+// and ErrInvalidEndpoint if the endpoint is not a known push service.
+func (c *Client) Send(ctx context.Context, sub Subscription, payload []byte, opts Options) error {
+	if err := ValidateEndpoint(sub.Endpoint); err != nil {
+		return err
+	}
+	if len(payload) > maxPayloadSize {
+		return fmt.Errorf("payload of %d bytes exceeds the maximum of %d bytes", len(payload), maxPayloadSize)
+	}
+
+	body, err := Encrypt(sub, payload)
+	if err != nil {
+		return fmt.Errorf("error encrypting payload: %w", err)
+	}
+
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid subscription endpoint: %w", err)
+	}
+	audience := endpoint.Scheme + "://" + endpoint.Host
+	authorization, err := c.keys.authorization(audience, c.subject, time.Now().Add(12*time.Hour))
+	if err != nil {
+		return fmt.Errorf("error signing VAPID token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+	if opts.Urgency != "" {
+		req.Header.Set("Urgency", opts.Urgency)
+	}
+	if opts.Topic != "" {
+		req.Header.Set("Topic", opts.Topic)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending push message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrSubscriptionGone
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("push service responded with %d: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// Encrypt encrypts the payload for the subscription as described in RFC 8291 using the aes128gcm
+// content coding from RFC 8188. The returned body includes the aes128gcm header.
+func Encrypt(sub Subscription, payload []byte) ([]byte, error) {
+	uaPublicBytes, err := decodeBase64(sub.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("%w: p256dh: %v", ErrInvalidKey, err)
+	}
+	authSecret, err := decodeBase64(sub.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("%w: auth: %v", ErrInvalidKey, err)
+	}
+
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: p256dh: %v", ErrInvalidKey, err)
+	}
+
+	// A new application server key pair and salt are used for every message.
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encrypt(uaPublic, authSecret, asPrivate, salt, payload)
+}
+
+// encrypt encrypts the payload for the user agent's public key and authentication secret with the
+// application server key pair and salt.
+func encrypt(uaPublic *ecdh.PublicKey, authSecret []byte, asPrivate *ecdh.PrivateKey, salt, payload []byte) ([]byte, error) {
+	uaPublicBytes := uaPublic.Bytes()
+	asPublicBytes := asPrivate.PublicKey().Bytes()
+
+	ecdhSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	// Combine the ECDH shared secret with the authentication secret (RFC 8291 section 3.4).
+	keyInfo := make([]byte, 0, 14+len(uaPublicBytes)+len(asPublicBytes))
+	keyInfo = append(keyInfo, "WebPush: info\x00"...)
+	keyInfo = append(keyInfo, uaPublicBytes...)
+	keyInfo = append(keyInfo, asPublicBytes...)
+	ikm := hkdf(authSecret, ecdhSecret, keyInfo, 32)
+
+	// Derive the content encryption key and nonce (RFC 8188 section 2.2).
+	cek := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// The payload is sent as a single, final record which is marked by the 0x02 delimiter.
+	plaintext := make([]byte, 0, len(payload)+1)
+	plaintext = append(plaintext, payload...)
+	plaintext = append(plaintext, 0x02)
+
+	header := make([]byte, 0, 16+4+1+len(asPublicBytes))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(asPublicBytes)))
+	header = append(header, asPublicBytes...)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// hkdf performs HKDF-SHA-256 extract and expand for output lengths no longer than a single hash block.
+func hkdf(salt, ikm, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(ikm)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	expand.Write(info)
+	expand.Write([]byte{0x01})
+	return expand.Sum(nil)[:length]
+}
+
+// decodeBase64 decodes base64url data with or without padding, as browsers are inconsistent.
+func decodeBase64(s string) ([]byte, error) {
+	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	if b, err := base64.URLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
@@ -0,0 +1,83 @@
+package webpush
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// TestEncryptRFC8291 encrypts the example message from RFC 8291 Appendix A with the keys and salt
+// given there, and checks the result matches the message body given there.
+func TestEncryptRFC8291(t *testing.T) {
+	decode := func(s string) []byte {
+		t.Helper()
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatalf("decoding %q: %v", s, err)
+		}
+		return b
+	}
+
+	var (
+		plaintext  = decode("V2hlbiBJIGdyb3cgdXAsIEkgd2FudCB0byBiZSBhIHdhdGVybWVsb24")
+		asPrivate  = decode("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw")
+		asPublic   = decode("BP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8")
+		uaPublic   = decode("BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4")
+		salt       = decode("DGv6ra1nlYgDCS1FRnbzlw")
+		authSecret = decode("BTBZMqHH6r4Tts7J_aSIgg")
+		want       = decode("DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN")
+	)
+
+	asKey, err := ecdh.P256().NewPrivateKey(asPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(asKey.PublicKey().Bytes(), asPublic) {
+		t.Fatal("application server public key does not match the private key")
+	}
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := encrypt(uaKey, authSecret, asKey, salt, plaintext)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encrypt =\n%s\nwant\n%s", base64.RawURLEncoding.EncodeToString(got), base64.RawURLEncoding.EncodeToString(want))
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		valid    bool
+	}{
+		{"https://fcm.googleapis.com/fcm/send/abc:def", true},
+		{"https://updates.push.services.mozilla.com/wpush/v2/abc", true},
+		{"https://web.push.apple.com/QGuQyavXutnMH", true},
+		{"https://wns2-par02p.notify.windows.com/w/?token=abc", true},
+		{"https://FCM.googleapis.com:443/fcm/send/abc", true},
+		{"http://fcm.googleapis.com/fcm/send/abc", false},
+		{"https://fcm.googleapis.com:8443/fcm/send/abc", false},
+		{"https://user@fcm.googleapis.com/fcm/send/abc", false},
+		{"https://evilfcm.googleapis.com.example.com/", false},
+		{"https://fcm.googleapis.com.example.com/", false},
+		{"https://127.0.0.1/", false},
+		{"https://169.254.169.254/latest/meta-data/", false},
+		{"https://localhost/", false},
+		{"not a url", false},
+	}
+	for _, tt := range tests {
+		err := ValidateEndpoint(tt.endpoint)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateEndpoint(%q) = %v, want nil", tt.endpoint, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidEndpoint) {
+			t.Errorf("ValidateEndpoint(%q) = %v, want ErrInvalidEndpoint", tt.endpoint, err)
+		}
+	}
+}